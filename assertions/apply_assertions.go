@@ -3,21 +3,29 @@ package assertions
 import (
 	"fmt"
 	"regexp"
-	"testing"
+	"strconv"
 
 	"github.com/gruntwork-io/terratest/modules/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/mitchellh/mapstructure"
+	"github.com/schrodinger/infra-tester/utils/cmd"
 	"github.com/stretchr/testify/assert"
 )
 
 type ApplyAssertions struct {
 	EnsureIdempotent bool `mapstructure:"ensure_idempotent"`
-	Assertions       []Assertion
+	// Stops the Terraform commands of the step once it's reached, e.g. "30m".
+	Timeout    string
+	Assertions []Assertion
 }
 
+var applySummaryRegexp = regexp.MustCompile(`Apply complete! Resources: (?:\d+ imported, )?(\d+) added, (\d+) changed, (\d+) destroyed`)
+
 type ApplyMetadata struct {
 	CmdOut string
 	Err    error
+	// Name of the test the apply belongs to.
+	TestName string
 }
 
 var ValidApplyAssertions = map[string]AssertionImplementation{
@@ -56,11 +64,26 @@ var ValidApplyAssertions = map[string]AssertionImplementation{
 		ValidateFunction: func(a Assertion) error { return nil },
 		RunFunction:      AssertNoResourcesAffected,
 	},
+
+	// State asserts
+
+	"ResourceExists": {
+		ValidateFunction: validateResourceExistsAssertion,
+		RunFunction:      AssertResourceExists,
+	},
+	"ResourceDoesNotExist": {
+		ValidateFunction: validateResourceExistsAssertion,
+		RunFunction:      AssertResourceDoesNotExist,
+	},
+	"ResourceAttributeEquals": {
+		ValidateFunction: validateResourceAttributeEqualsAssertion,
+		RunFunction:      AssertResourceAttributeEquals,
+	},
 }
 
 // ------------------------------------------------------------------------------------------------------------------------------
 
-func AssertApplySucceeds(t *testing.T, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+func AssertApplySucceeds(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
 	var applyMetadata ApplyMetadata
 	var ok bool
 	if applyMetadata, ok = stepMetadata.(ApplyMetadata); !ok {
@@ -110,7 +133,7 @@ func validateOutputEqualAssertion(assertion Assertion) error {
 	return nil
 }
 
-func AssertOutputEqual(t *testing.T, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+func AssertOutputEqual(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
 	var outputEqualMetadata outputEqualMetadata
 
 	err := mapstructure.Decode(assertion.Metadata, &outputEqualMetadata)
@@ -121,7 +144,12 @@ func AssertOutputEqual(t *testing.T, terraformOptions *terraform.Options, assert
 	// Get properties
 	outputName := outputEqualMetadata.OutputName
 	expectedValue := outputEqualMetadata.Value["value"]
-	outputValue := terraform.OutputAll(t, terraformOptions)[outputName]
+	outputs, err := cmd.OutputAllE(Context(t), t, terraformOptions)
+	if err != nil {
+		ErrorAndSkipf(t, "ERROR: Failed to get terraform outputs: %s", err)
+	}
+
+	outputValue := outputs[outputName]
 
 	partialComparisonResult := partialDeepCompare(expectedValue, outputValue)
 	if partialComparisonResult != nil {
@@ -144,6 +172,9 @@ type resourcesModifiedMetadata struct {
 	Added     int
 	Changed   int
 	Destroyed int
+	// Optional glob to only count resources with a matching address. This is
+	// only supported in the plan step as apply output has no per-resource counts.
+	Address string
 }
 
 func validateResourcesModified(assertion Assertion) error {
@@ -154,14 +185,27 @@ func validateResourcesModified(assertion Assertion) error {
 		return fmt.Errorf("error decoding assertion metadata: %s", err)
 	}
 
-	if len(decoderMetadata.Keys) == 0 {
-		return fmt.Errorf("at least one of the following keys must be specified: Added, Changed, Destroyed")
+	for _, key := range decoderMetadata.Keys {
+		if key == "Address" {
+			return fmt.Errorf("address is only supported for the plan step")
+		}
 	}
 
-	return nil
+	return validateResourceCountKeys(decoderMetadata)
+}
+
+// Checks that at least one of the resource counts is specified.
+func validateResourceCountKeys(decoderMetadata mapstructure.Metadata) error {
+	for _, key := range decoderMetadata.Keys {
+		if key == "Added" || key == "Changed" || key == "Destroyed" {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("at least one of the following keys must be specified: Added, Changed, Destroyed")
 }
 
-func AssertResourcesAffected(t *testing.T, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+func AssertResourcesAffected(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
 	var resourcesModifiedMetadata resourcesModifiedMetadata
 	decoderMetadata, err := decodeWithMetadata(assertion, &resourcesModifiedMetadata)
 	if err != nil {
@@ -175,9 +219,37 @@ func AssertResourcesAffected(t *testing.T, terraformOptions *terraform.Options,
 		ErrorAndSkip(t, "stepMetadata is not of type ApplyMetadata")
 	}
 
-	resourcesCount := terraform.GetResourceCount(t, applyMetadata.CmdOut)
+	resourcesCount, err := getApplyResourceCount(applyMetadata.CmdOut)
+	if err != nil {
+		ErrorAndSkipf(t, "%s", err)
+	}
+
+	assertResourceCounts(t, decoderMetadata, resourcesModifiedMetadata, resourcesCount)
+}
 
-	// Only check for keys explicitly specified in yaml config
+// Parses the resource counts from the summary printed by apply. Unlike
+// terraform.GetResourceCount, this also supports the imported and forgotten
+// counts printed by newer Terraform and OpenTofu versions.
+func getApplyResourceCount(cmdOut string) (*terraform.ResourceCount, error) {
+	matches := applySummaryRegexp.FindStringSubmatch(cmdOut)
+	if matches == nil {
+		return nil, fmt.Errorf("can't parse the resource counts from the apply output")
+	}
+
+	added, _ := strconv.Atoi(matches[1])
+	changed, _ := strconv.Atoi(matches[2])
+	destroyed, _ := strconv.Atoi(matches[3])
+
+	return &terraform.ResourceCount{Add: added, Change: changed, Destroy: destroyed}, nil
+}
+
+// Compares the resource counts, only checking for keys explicitly specified in
+// the yaml config.
+func assertResourceCounts(
+	t TestingT,
+	decoderMetadata mapstructure.Metadata,
+	resourcesModifiedMetadata resourcesModifiedMetadata,
+	resourcesCount *terraform.ResourceCount) {
 	for _, key := range decoderMetadata.Keys {
 		if key == "Added" {
 			assert.Equal(t, resourcesModifiedMetadata.Added, resourcesCount.Add, "Unexpected number of resources were added.")
@@ -189,7 +261,7 @@ func AssertResourcesAffected(t *testing.T, terraformOptions *terraform.Options,
 	}
 }
 
-func AssertNoResourcesAffected(t *testing.T, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+func AssertNoResourcesAffected(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
 	assertion.Metadata = map[interface{}]interface{}{
 		"added":     0,
 		"changed":   0,
@@ -220,7 +292,7 @@ func validateOutputsAreEqualAssertion(assertion Assertion) error {
 	return nil
 }
 
-func AssertOutputsAreEqual(t *testing.T, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+func AssertOutputsAreEqual(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
 	var outputsAreEqualMetadata outputsAreEqualMetadata
 
 	err := mapstructure.Decode(assertion.Metadata, &outputsAreEqualMetadata)
@@ -237,8 +309,8 @@ func AssertOutputsAreEqual(t *testing.T, terraformOptions *terraform.Options, as
 		outputName1 := outputsAreEqualMetadata.OutputNames[i-1]
 		outputName2 := outputsAreEqualMetadata.OutputNames[i]
 
-		outputValue1 := terraform.Output(t, terraformOptions, outputName1)
-		outputValue2 := terraform.Output(t, terraformOptions, outputName2)
+		outputValue1 := getOutput(t, terraformOptions, outputName1)
+		outputValue2 := getOutput(t, terraformOptions, outputName2)
 
 		assert.Equal(t, outputValue1, outputValue2, "The values for output \""+outputName1+"\" ("+outputValue1+") and \""+outputName2+"\" ("+outputValue2+") do not match")
 	}
@@ -270,7 +342,7 @@ func validateOutputContainsAssertion(assertion Assertion) error {
 	return nil
 }
 
-func AssertOutputContains(t *testing.T, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+func AssertOutputContains(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
 	var outputContainsMetadata outputContainsMetadata
 
 	err := mapstructure.Decode(assertion.Metadata, &outputContainsMetadata)
@@ -281,7 +353,7 @@ func AssertOutputContains(t *testing.T, terraformOptions *terraform.Options, ass
 	// Get properties
 	outputName := outputContainsMetadata.OutputName
 	shouldContain := outputContainsMetadata.Value
-	outputValue := terraform.Output(t, terraformOptions, outputName)
+	outputValue := getOutput(t, terraformOptions, outputName)
 
 	assert.Contains(t, outputValue, shouldContain, "The property \""+outputName+"\" has an unexpected value. It does not contain the string \""+shouldContain+"\". Value is: \""+outputValue+"\".")
 }
@@ -316,7 +388,7 @@ func validateOutputMatchesRegexAssertion(assertion Assertion) error {
 	return nil
 }
 
-func AssertOutputMatchesRegex(t *testing.T, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+func AssertOutputMatchesRegex(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
 	var outputMatchesMetadata outputMatchesRegexMetadata
 
 	err := mapstructure.Decode(assertion.Metadata, &outputMatchesMetadata)
@@ -327,8 +399,192 @@ func AssertOutputMatchesRegex(t *testing.T, terraformOptions *terraform.Options,
 	// Get properties
 	outputName := outputMatchesMetadata.OutputName
 	regex := outputMatchesMetadata.Regex
-	outputValue := terraform.Output(t, terraformOptions, outputName)
+	outputValue := getOutput(t, terraformOptions, outputName)
 
 	// regexp is already validated in validateOutputMatchesAssertion so there shouldn't be any panic
 	assert.Regexp(t, regexp.MustCompile(regex), outputValue, "The property \""+outputName+"\" has an unexpected value. It does not match the regular expression \""+regex+"\". Value is: \""+outputValue+"\".")
 }
+
+// ------------------------------------------------------------------------------------------------------------------------------
+
+type resourceExistsMetadata struct {
+	Address string
+}
+
+func validateResourceExistsAssertion(assertion Assertion) error {
+	var resourceExistsMetadata resourceExistsMetadata
+
+	err := mapstructure.Decode(assertion.Metadata, &resourceExistsMetadata)
+	if err != nil {
+		return fmt.Errorf("error decoding assertion metadata: %s", err)
+	}
+
+	if resourceExistsMetadata.Address == "" {
+		return fmt.Errorf("address is either not defined or is empty")
+	}
+
+	return nil
+}
+
+func AssertResourceExists(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+	var resourceExistsMetadata resourceExistsMetadata
+
+	err := mapstructure.Decode(assertion.Metadata, &resourceExistsMetadata)
+	if err != nil {
+		ErrorAndSkipf(t, "error decoding assertion metadata: %s", err)
+	}
+
+	address := resourceExistsMetadata.Address
+	stateResources := getStateResources(t, terraformOptions)
+
+	if _, ok := stateResources[address]; !ok {
+		ErrorAndSkipf(t, "The resource %s is expected to exist in the state but it does not.", address)
+	}
+}
+
+func AssertResourceDoesNotExist(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+	var resourceExistsMetadata resourceExistsMetadata
+
+	err := mapstructure.Decode(assertion.Metadata, &resourceExistsMetadata)
+	if err != nil {
+		ErrorAndSkipf(t, "error decoding assertion metadata: %s", err)
+	}
+
+	address := resourceExistsMetadata.Address
+	stateResources := getStateResources(t, terraformOptions)
+
+	if _, ok := stateResources[address]; ok {
+		ErrorAndSkipf(t, "The resource %s is expected to be absent from the state but it exists.", address)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------------------------------
+
+type resourceAttributeEqualsMetadata struct {
+	Address       string
+	AttributePath string                      `mapstructure:"attribute_path"`
+	CompleteMatch bool                        `mapstructure:"complete_match"`
+	Value         map[interface{}]interface{} `mapstructure:",remain"`
+}
+
+func validateResourceAttributeEqualsAssertion(assertion Assertion) error {
+	var resourceAttributeEqualsMetadata resourceAttributeEqualsMetadata
+
+	err := mapstructure.Decode(assertion.Metadata, &resourceAttributeEqualsMetadata)
+	if err != nil {
+		return fmt.Errorf("error decoding assertion metadata: %s", err)
+	}
+
+	if resourceAttributeEqualsMetadata.Address == "" {
+		return fmt.Errorf("address is either not defined or is empty")
+	}
+
+	val, ok := resourceAttributeEqualsMetadata.Value["value"]
+	if !ok {
+		return fmt.Errorf("value is not defined")
+	}
+
+	if val == nil {
+		return fmt.Errorf("value can not be empty")
+	}
+
+	for key := range resourceAttributeEqualsMetadata.Value {
+		if key != "value" {
+			return fmt.Errorf("unexpected key: %s", key)
+		}
+	}
+
+	return nil
+}
+
+func AssertResourceAttributeEquals(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+	var resourceAttributeEqualsMetadata resourceAttributeEqualsMetadata
+
+	err := mapstructure.Decode(assertion.Metadata, &resourceAttributeEqualsMetadata)
+	if err != nil {
+		ErrorAndSkipf(t, "error decoding assertion metadata: %s", err)
+	}
+
+	// Get properties
+	address := resourceAttributeEqualsMetadata.Address
+	attributePath := resourceAttributeEqualsMetadata.AttributePath
+	expectedValue := resourceAttributeEqualsMetadata.Value["value"]
+
+	stateResource, ok := getStateResources(t, terraformOptions)[address]
+	if !ok {
+		ErrorAndSkipf(t, "The resource %s does not exist in the state.", address)
+	}
+
+	// Compare against all the attributes if no attribute path is specified.
+	var attributeValue interface{} = stateResource.AttributeValues
+	if attributePath != "" {
+		attributeValue, err = getValueAtPath(attributeValue, attributePath)
+		if err != nil {
+			ErrorAndSkipf(t, "Could not find the attribute %s of %s in the state: %s", attributePath, address, err)
+		}
+	} else {
+		attributePath = "values"
+	}
+
+	partialComparisonResult := partialDeepCompare(expectedValue, attributeValue)
+	if partialComparisonResult != nil {
+		ErrorAndSkipf(t, "The attribute %s of %s has an unexpected value.\n\nExpected value:\n%+v\n\nActual Value:\n%+v\n\nReason: %s", attributePath, address, expectedValue, attributeValue, partialComparisonResult.Error())
+	}
+
+	if !resourceAttributeEqualsMetadata.CompleteMatch {
+		return
+	}
+
+	fullComparisonResult := partialDeepCompare(attributeValue, expectedValue)
+	if fullComparisonResult != nil {
+		ErrorAndSkipf(t, "The attribute %s of %s has an unexpected value.\n\nExpected following value(s):\n%+v\n\nActual value:\n%+v\n\nReason: %s", attributePath, address, expectedValue, attributeValue, fullComparisonResult.Error())
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------------------------------
+
+// Returns the value of the output formatted as a string, stopping terraform
+// output once the context of the assertion is done.
+func getOutput(t TestingT, terraformOptions *terraform.Options, outputName string) string {
+	outputValue, err := cmd.OutputE(Context(t), t, terraformOptions, outputName)
+	if err != nil {
+		ErrorAndSkipf(t, "ERROR: Failed to get terraform output %s: %s", outputName, err)
+	}
+
+	return outputValue
+}
+
+// Loads the current terraform state and returns a map of full resource
+// addresses (including the ones in child modules) to the resources.
+func getStateResources(t TestingT, terraformOptions *terraform.Options) map[string]*tfjson.StateResource {
+	stateJSON, err := cmd.ShowE(Context(t), t, terraformOptions)
+	if err != nil {
+		ErrorAndSkipf(t, "ERROR: Failed to get terraform state: %s", err)
+	}
+
+	var state tfjson.State
+	if err := state.UnmarshalJSON([]byte(stateJSON)); err != nil {
+		ErrorAndSkipf(t, "ERROR: Failed to parse terraform state: %s", err)
+	}
+
+	stateResources := map[string]*tfjson.StateResource{}
+	if state.Values == nil || state.Values.RootModule == nil {
+		// Nothing has been applied yet.
+		return stateResources
+	}
+
+	collectStateResources(state.Values.RootModule, stateResources)
+
+	return stateResources
+}
+
+func collectStateResources(module *tfjson.StateModule, stateResources map[string]*tfjson.StateResource) {
+	for _, resource := range module.Resources {
+		// The address of resources in child modules is always the full address.
+		stateResources[resource.Address] = resource
+	}
+
+	for _, childModule := range module.ChildModules {
+		collectStateResources(childModule, stateResources)
+	}
+}
@@ -1,10 +1,13 @@
 package assertions
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/gruntwork-io/terratest/modules/terraform"
+	terratesting "github.com/gruntwork-io/terratest/modules/testing"
 	"github.com/schrodinger/infra-tester/plugins"
 	"github.com/schrodinger/infra-tester/utils"
 )
@@ -12,6 +15,8 @@ import (
 type Assertion struct {
 	Type     string
 	Name     string
+	Retry    *Retry
+	Timeout  string
 	Metadata map[interface{}]interface{} `mapstructure:",remain"`
 }
 
@@ -26,6 +31,21 @@ func (assertion Assertion) ToGenericMap() map[string]interface{} {
 	return genericMap
 }
 
+// TestingT is the interface assertions use to report their results. It is
+// implemented by *testing.T, but allows the runner to record or intercept
+// the results of assertions.
+type TestingT interface {
+	terratesting.TestingT
+
+	Helper()
+	Log(args ...any)
+	Logf(format string, args ...any)
+	Failed() bool
+	SkipNow()
+	Skipped() bool
+	Run(name string, f func(t *testing.T)) bool
+}
+
 type AssertionContext struct {
 	AvailablePlugins map[string]bool
 	PluginManager    *plugins.PluginManager
@@ -33,18 +53,60 @@ type AssertionContext struct {
 
 type AssertionImplementation struct {
 	ValidateFunction func(Assertion) error
-	RunFunction      func(t *testing.T, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{})
+	RunFunction      func(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{})
+	// Optional, runs once the assertion is done including all its retries, in
+	// a separate Cleanup sub test of the assertion. It runs regardless of
+	// whether the assertion passed, failed or was skipped, and its failures
+	// are reported on the assertion.
+	CleanupFunction func(t TestingT, terraformOptions *terraform.Options, assertion Assertion)
+}
+
+// Register adds an assertion type implemented in Go for the given step, either
+// "plan" or "apply", so that it can be used in the config like the inbuilt
+// assertions. It must be called before running any tests, e.g. from the main
+// function of a binary calling runner.Main. Registering a name which is
+// already taken for the step, including inbuilt assertions, is an error.
+func Register(step string, name string, assertionImplementation AssertionImplementation) error {
+	var validAssertions map[string]AssertionImplementation
+	switch step {
+	case "plan":
+		validAssertions = ValidPlanAssertions
+	case "apply":
+		validAssertions = ValidApplyAssertions
+	default:
+		return fmt.Errorf("step '%s' is invalid", step)
+	}
+
+	if name == "" {
+		return fmt.Errorf("assertion name must not be empty")
+	}
+
+	if assertionImplementation.ValidateFunction == nil || assertionImplementation.RunFunction == nil {
+		return fmt.Errorf("assertion '%s' must define both ValidateFunction and RunFunction", name)
+	}
+
+	if _, ok := validAssertions[name]; ok || isCompositeAssertion(name) {
+		return fmt.Errorf("assertion '%s' is already registered for the %s step", name, step)
+	}
+
+	validAssertions[name] = assertionImplementation
+
+	return nil
 }
 
 func GetAssertionImplementation(assertionType string, step string, assertionContext *AssertionContext) (AssertionImplementation, error) {
 	var assertionImplementation AssertionImplementation
 	var ok bool
+	if isCompositeAssertion(assertionType) && (step == "plan" || step == "apply") {
+		return getCompositeAssertionImplementation(assertionType, step, assertionContext), nil
+	}
+
 	if step == "plan" {
 		if assertionImplementation, ok = ValidPlanAssertions[assertionType]; !ok {
 			// It could be a plugin assertion type.
 			if assertionContext.PluginManager != nil {
 				if _, ok := assertionContext.AvailablePlugins[assertionType]; ok {
-					return GetCustomAssertionImplementation(assertionType, assertionContext.PluginManager)
+					return GetCustomAssertionImplementation(assertionType, step, assertionContext.PluginManager)
 				}
 			}
 
@@ -60,7 +122,7 @@ func GetAssertionImplementation(assertionType string, step string, assertionCont
 			// It could be a plugin assertion type.
 			if assertionContext.PluginManager != nil {
 				if _, ok := assertionContext.AvailablePlugins[assertionType]; ok {
-					return GetCustomAssertionImplementation(assertionType, assertionContext.PluginManager)
+					return GetCustomAssertionImplementation(assertionType, step, assertionContext.PluginManager)
 				}
 			}
 
@@ -84,17 +146,34 @@ func ValidateAssertion(assertion Assertion, step string, assertionContext *Asser
 		return err
 	}
 
+	if assertion.Retry != nil {
+		if err := validateRetry(*assertion.Retry); err != nil {
+			return err
+		}
+	}
+
+	if assertion.Timeout != "" {
+		if err := validateTimeout(assertion.Timeout); err != nil {
+			return err
+		}
+	}
+
 	validateFunction := AssertionImplementation.ValidateFunction
 	return validateFunction(assertion)
 }
 
+// Runs the assertion, which stops running Terraform commands and plugins once
+// the context is done, e.g. because the step timed out.
 func RunAssertion(
-	t *testing.T,
+	ctx context.Context,
+	t TestingT,
 	terraformOptions *terraform.Options,
 	assertion Assertion,
 	step string,
 	stepMetadata interface{},
 	assertionContext *AssertionContext) {
+	t = withContext(t, ctx)
+
 	assertionType := assertion.Type
 	var assertionImplementation AssertionImplementation
 
@@ -104,16 +183,77 @@ func RunAssertion(
 		ErrorAndSkipf(t, "ERROR: Failure while running assertion: %s.\n", err)
 	}
 
+	// Resolve template expressions before running the assertion, so that
+	// neither the assertions nor the plugins have to deal with them.
+	assertion, err = resolveAssertionTemplates(t, terraformOptions, assertion, step)
+	if err != nil {
+		ErrorAndSkipf(t, "ERROR: Failed to resolve assertion inputs: %s", err)
+	}
+
+	// Cleanup runs outside of the retried function, so that only the final
+	// attempt decides whether the test fails.
+	if cleanupFunction := assertionImplementation.CleanupFunction; cleanupFunction != nil {
+		defer t.Run("Cleanup", func(subT *testing.T) {
+			cleanupCtx, cancel := cleanupContext(ctx)
+			defer cancel()
+
+			cleanupFunction(withContext(wrapSubTest(t, subT), cleanupCtx), terraformOptions, assertion)
+		})
+	}
+
+	runAssertionImplementation(t, assertionImplementation, terraformOptions, assertion, stepMetadata)
+}
+
+// Runs the assertion with the given implementation, retrying it if the
+// assertion has a retry config and bounding it if it has a timeout.
+func runAssertionImplementation(
+	t TestingT,
+	assertionImplementation AssertionImplementation,
+	terraformOptions *terraform.Options,
+	assertion Assertion,
+	stepMetadata interface{}) {
+	if assertion.Timeout != "" {
+		// The timeout is already validated.
+		timeout, _ := time.ParseDuration(assertion.Timeout)
+		assertionName := assertion.Type
+		if assertion.Name != "" {
+			assertionName = assertion.Name
+		}
+
+		runWithTimeout(t, assertionName, timeout, func(t TestingT) {
+			runAssertionWithRetry(t, assertionImplementation, terraformOptions, assertion, stepMetadata)
+		})
+
+		return
+	}
+
+	runAssertionWithRetry(t, assertionImplementation, terraformOptions, assertion, stepMetadata)
+}
+
+func runAssertionWithRetry(
+	t TestingT,
+	assertionImplementation AssertionImplementation,
+	terraformOptions *terraform.Options,
+	assertion Assertion,
+	stepMetadata interface{}) {
 	runFunction := assertionImplementation.RunFunction
-	runFunction(t, terraformOptions, assertion, stepMetadata)
+	if assertion.Retry == nil {
+		runFunction(t, terraformOptions, assertion, stepMetadata)
+
+		return
+	}
+
+	runWithRetry(t, *assertion.Retry, func(t TestingT) {
+		runFunction(t, terraformOptions, assertion, stepMetadata)
+	})
 }
 
-func ErrorAndSkip(t *testing.T, args ...any) {
+func ErrorAndSkip(t TestingT, args ...any) {
 	t.Error(args...)
 	t.SkipNow()
 }
 
-func ErrorAndSkipf(t *testing.T, format string, args ...any) {
+func ErrorAndSkipf(t TestingT, format string, args ...any) {
 	t.Errorf(format, args...)
 	t.SkipNow()
 }
@@ -0,0 +1,94 @@
+package assertions
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunAssertionCleanup(t *testing.T) {
+	tests := []struct {
+		name      string
+		retry     *Retry
+		cancelled bool
+		// Runs an attempt of the assertion, the attempts are counted from 1.
+		run      func(t TestingT, attempt int)
+		expected []string
+	}{
+		{
+			name:     "cleanup runs after the assertion passed",
+			run:      func(t TestingT, attempt int) {},
+			expected: []string{"run 1", "cleanup"},
+		},
+		{
+			name:  "cleanup runs once after all the attempts",
+			retry: &Retry{Attempts: 3, Interval: "0s"},
+			run: func(t TestingT, attempt int) {
+				if attempt < 3 {
+					t.Errorf("attempt %d failed", attempt)
+				}
+			},
+			expected: []string{"run 1", "run 2", "run 3", "cleanup"},
+		},
+		{
+			name: "cleanup runs after the assertion was skipped",
+			run: func(t TestingT, attempt int) {
+				t.SkipNow()
+			},
+			expected: []string{"run 1", "cleanup"},
+		},
+		{
+			name:      "cleanup runs with a live context after the context of the step is done",
+			cancelled: true,
+			run:       func(t TestingT, attempt int) {},
+			expected:  []string{"run 1", "cleanup"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			events := []string{}
+			attempt := 0
+
+			implementation := AssertionImplementation{
+				ValidateFunction: func(Assertion) error { return nil },
+				RunFunction: func(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+					mu.Lock()
+					attempt++
+					currentAttempt := attempt
+					events = append(events, fmt.Sprintf("run %d", attempt))
+					mu.Unlock()
+
+					test.run(t, currentAttempt)
+				},
+				CleanupFunction: func(t TestingT, terraformOptions *terraform.Options, assertion Assertion) {
+					assert.NoError(t, Context(t).Err(), "cleanup must be able to run commands")
+
+					mu.Lock()
+					events = append(events, "cleanup")
+					mu.Unlock()
+				},
+			}
+
+			ValidApplyAssertions["CleanupTest"] = implementation
+			defer delete(ValidApplyAssertions, "CleanupTest")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if test.cancelled {
+				cancel()
+			}
+
+			t.Run("Assertion", func(t *testing.T) {
+				RunAssertion(ctx, t, &terraform.Options{}, Assertion{Type: "CleanupTest", Retry: test.retry}, "apply", nil, &AssertionContext{})
+			})
+
+			assert.Equal(t, test.expected, events)
+		})
+	}
+}
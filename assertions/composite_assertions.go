@@ -0,0 +1,189 @@
+package assertions
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// Composite assertions wrap other assertions of the same step. They are valid
+// for both the plan and the apply step.
+const (
+	NOT    = "Not"
+	ANY_OF = "AnyOf"
+	ALL_OF = "AllOf"
+)
+
+type compositeAssertionMetadata struct {
+	Assertions []Assertion
+}
+
+func isCompositeAssertion(assertionType string) bool {
+	return assertionType == NOT || assertionType == ANY_OF || assertionType == ALL_OF
+}
+
+// Returns the implementation of a composite assertion for the given step. The
+// nested assertions are validated and run as assertions of the same step, and
+// their cleanup runs with the cleanup of the composite assertion.
+func getCompositeAssertionImplementation(assertionType string, step string, assertionContext *AssertionContext) AssertionImplementation {
+	nested := &nestedImplementations{step: step, assertionContext: assertionContext}
+
+	return AssertionImplementation{
+		ValidateFunction: func(assertion Assertion) error {
+			return validateCompositeAssertion(assertion, step, assertionContext)
+		},
+		RunFunction: func(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+			runCompositeAssertion(t, terraformOptions, assertion, stepMetadata, nested)
+		},
+		CleanupFunction: func(t TestingT, terraformOptions *terraform.Options, assertion Assertion) {
+			nested.cleanup(t, terraformOptions)
+		},
+	}
+}
+
+// nestedImplementations holds the implementations of the nested assertions of
+// a composite assertion, so that every attempt of a retried composite assertion
+// runs the same implementations, and their cleanup runs once for all of them.
+type nestedImplementations struct {
+	step             string
+	assertionContext *AssertionContext
+
+	mu              sync.Mutex
+	assertions      []Assertion
+	implementations []AssertionImplementation
+}
+
+// Returns the implementation of the nested assertion at the given index.
+func (n *nestedImplementations) get(index int, nestedAssertion Assertion) (AssertionImplementation, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if index < len(n.implementations) {
+		return n.implementations[index], nil
+	}
+
+	implementation, err := GetAssertionImplementation(nestedAssertion.Type, n.step, n.assertionContext)
+	if err != nil {
+		return AssertionImplementation{}, err
+	}
+
+	n.assertions = append(n.assertions, nestedAssertion)
+	n.implementations = append(n.implementations, implementation)
+
+	return implementation, nil
+}
+
+// Runs the cleanup of the nested assertions which ran.
+func (n *nestedImplementations) cleanup(t TestingT, terraformOptions *terraform.Options) {
+	n.mu.Lock()
+	assertions := append([]Assertion{}, n.assertions...)
+	implementations := append([]AssertionImplementation{}, n.implementations...)
+	n.mu.Unlock()
+
+	for i, implementation := range implementations {
+		if implementation.CleanupFunction != nil {
+			implementation.CleanupFunction(t, terraformOptions, assertions[i])
+		}
+	}
+}
+
+func decodeCompositeAssertion(assertion Assertion) ([]Assertion, error) {
+	var compositeMetadata compositeAssertionMetadata
+	decoderMetadata, err := decodeWithMetadata(assertion, &compositeMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(decoderMetadata.Unused) > 0 {
+		return nil, fmt.Errorf("unexpected keys: %s", strings.Join(decoderMetadata.Unused, ", "))
+	}
+
+	return compositeMetadata.Assertions, nil
+}
+
+func validateCompositeAssertion(assertion Assertion, step string, assertionContext *AssertionContext) error {
+	nestedAssertions, err := decodeCompositeAssertion(assertion)
+	if err != nil {
+		return err
+	}
+
+	if assertion.Type == NOT && len(nestedAssertions) != 1 {
+		return fmt.Errorf("%s requires exactly one assertion in 'assertions'", NOT)
+	}
+
+	if len(nestedAssertions) == 0 {
+		return fmt.Errorf("%s requires at least one assertion in 'assertions'", assertion.Type)
+	}
+
+	for _, nestedAssertion := range nestedAssertions {
+		if err := ValidateAssertion(nestedAssertion, step, assertionContext); err != nil {
+			return fmt.Errorf("nested assertion '%s' failed validation because - %s", nestedAssertion.Type, err)
+		}
+	}
+
+	return nil
+}
+
+// Runs the nested assertions against a recordingT each, so that their failures
+// are collected and only reported depending on the composite assertion type.
+func runCompositeAssertion(
+	t TestingT,
+	terraformOptions *terraform.Options,
+	assertion Assertion,
+	stepMetadata interface{},
+	nested *nestedImplementations) {
+	nestedAssertions, err := decodeCompositeAssertion(assertion)
+	if err != nil {
+		ErrorAndSkipf(t, "error while decoding assertion metadata: %s", err)
+	}
+
+	passed := 0
+	failed := 0
+	failures := []string{}
+	for i, nestedAssertion := range nestedAssertions {
+		nestedAssertionName := nestedAssertion.Type
+		if nestedAssertion.Name != "" {
+			nestedAssertionName = nestedAssertion.Name
+		}
+
+		implementation, err := nested.get(i, nestedAssertion)
+		if err != nil {
+			// This shouldn't happen as we are validating the tests before running them
+			ErrorAndSkipf(t, "ERROR: Failure while running nested assertion: %s.\n", err)
+		}
+
+		recorded := runRecorded(t, func(t TestingT) {
+			runAssertionImplementation(t, implementation, terraformOptions, nestedAssertion, stepMetadata)
+		})
+
+		switch {
+		case recorded.Failed():
+			failed++
+			failures = append(failures, fmt.Sprintf("'%s' failed: %s", nestedAssertionName, strings.Join(recorded.Failures(), "\n")))
+		case recorded.Skipped():
+			failures = append(failures, fmt.Sprintf("'%s' was skipped", nestedAssertionName))
+		default:
+			passed++
+			t.Logf("INFO: Nested assertion '%s' passed", nestedAssertionName)
+		}
+	}
+
+	switch assertion.Type {
+	case NOT:
+		if passed > 0 {
+			t.Errorf("The nested assertion passed, but it is expected to fail.")
+		} else if failed == 0 {
+			t.Errorf("The nested assertion is expected to fail, but %s", strings.Join(failures, "\n"))
+		}
+	case ANY_OF:
+		if passed == 0 {
+			t.Errorf("None of the nested assertions passed:\n%s", strings.Join(failures, "\n"))
+		}
+	case ALL_OF:
+		if len(failures) > 0 {
+			t.Errorf("%d of %d nested assertions did not pass:\n%s", len(failures), len(nestedAssertions), strings.Join(failures, "\n"))
+		}
+	}
+}
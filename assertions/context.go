@@ -0,0 +1,47 @@
+package assertions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/schrodinger/infra-tester/report"
+)
+
+// contextT is a TestingT carrying the context an assertion runs with.
+type contextT struct {
+	TestingT
+	ctx context.Context
+}
+
+// Returns a TestingT which reports to t, and whose context is ctx.
+func withContext(t TestingT, ctx context.Context) TestingT {
+	return &contextT{TestingT: t, ctx: ctx}
+}
+
+// Context returns the context an assertion runs with. It is done once the
+// assertion must stop, e.g. because its step or the assertion itself timed
+// out, so that the commands it runs can be stopped. Retries stop as well once
+// it's done.
+func Context(t TestingT) context.Context {
+	switch t := t.(type) {
+	case *contextT:
+		return t.ctx
+	case *recordingT:
+		return t.ctx
+	}
+
+	return context.Background()
+}
+
+// Returns a TestingT for the sub test t of parent which reports like parent
+// does, e.g. recording its failures on the result of the assertion.
+func wrapSubTest(parent TestingT, t *testing.T) TestingT {
+	switch parent := parent.(type) {
+	case *contextT:
+		return wrapSubTest(parent.TestingT, t)
+	case *report.T:
+		return parent.Sub(t)
+	}
+
+	return t
+}
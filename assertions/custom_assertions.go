@@ -1,16 +1,21 @@
 package assertions
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"testing"
+	"sync"
 
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/schrodinger/infra-tester/plugins"
+	"github.com/schrodinger/infra-tester/utils"
+	"github.com/schrodinger/infra-tester/utils/cmd"
 	"github.com/stretchr/testify/assert"
 )
 
 func GetCustomAssertionImplementation(
 	assertionType string,
+	step string,
 	pluginManager *plugins.PluginManager) (AssertionImplementation, error) {
 
 	// Get the plugin runner for the assertion type
@@ -19,19 +24,102 @@ func GetCustomAssertionImplementation(
 		return AssertionImplementation{}, fmt.Errorf("failed to get plugin runner for %s: %s", assertionType, err)
 	}
 
+	// The state of the last run of the assertion, which the cleanup gets. A
+	// run which timed out may still be setting it while the cleanup runs.
+	var mu sync.Mutex
+	var state *string
+
 	return AssertionImplementation{
 		ValidateFunction: func(assertion Assertion) error {
 			return pluginRunner.ValidateInputs(assertion)
 		},
-		RunFunction: func(t *testing.T, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+		RunFunction: func(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+			ctx := Context(t)
+
 			t.Log("INFO: Running custom assertion")
-			terraformState, err := terraform.ShowE(t, terraformOptions)
+			terraformState, err := cmd.ShowE(ctx, t, terraformOptions)
 			if err != nil {
 				ErrorAndSkipf(t, "ERROR: Failed to get terraform state: %s", err)
 			}
 
-			err = pluginRunner.Run(t, assertion, &terraformState)
+			mu.Lock()
+			state = &terraformState
+			mu.Unlock()
+
+			pluginContext, err := buildPluginContext(ctx, t, terraformOptions, step, stepMetadata, terraformState)
+			if err != nil {
+				ErrorAndSkipf(t, "ERROR: Failed to build the plugin context: %s", err)
+			}
+
+			err = pluginRunner.Run(ctx, t, assertion, &terraformState, pluginContext)
 			assert.Nilf(t, err, "assertion '%s' failed: %s", assertion.Name, err)
 		},
+		// Cleanup only runs once for all the attempts of a retried assertion,
+		// cleanup of plugins is idempotent. It also runs if the assertion was
+		// stopped, e.g. because it timed out, and is only interrupted once its
+		// context is done or --plugin-timeout is reached.
+		CleanupFunction: func(t TestingT, terraformOptions *terraform.Options, assertion Assertion) {
+			mu.Lock()
+			cleanupState := state
+			mu.Unlock()
+
+			err := pluginRunner.Cleanup(Context(t), t, assertion, cleanupState)
+			assert.Nilf(t, err, "cleanup for assertion '%s' failed: %s", assertion.Name, err)
+		},
 	}, nil
 }
+
+// Builds the context passed to plugins when running an assertion. The plan is
+// only included in the plan step, and the state and outputs only in the apply
+// step, as the state before the apply doesn't reflect the changes under test.
+func buildPluginContext(
+	ctx context.Context,
+	t TestingT,
+	terraformOptions *terraform.Options,
+	step string,
+	stepMetadata interface{},
+	terraformState string) (*plugins.PluginContext, error) {
+	pluginContext := plugins.PluginContext{Step: step}
+
+	if vars, ok := utils.ConvertToGenericInterface(terraformOptions.Vars).(map[string]interface{}); ok {
+		pluginContext.Vars = vars
+	}
+
+	var stepErr error
+	switch stepMetadata := stepMetadata.(type) {
+	case PlanMetadata:
+		pluginContext.TestName = stepMetadata.TestName
+		stepErr = stepMetadata.Err
+
+		if stepMetadata.Plan != nil {
+			plan, err := json.Marshal(stepMetadata.Plan.RawPlan)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert the plan to JSON: %s", err)
+			}
+
+			pluginContext.Plan = plan
+		}
+	case ApplyMetadata:
+		pluginContext.TestName = stepMetadata.TestName
+		stepErr = stepMetadata.Err
+		if terraformState != "" {
+			pluginContext.State = json.RawMessage(terraformState)
+		}
+
+		outputs, err := cmd.OutputAllE(ctx, t, terraformOptions)
+		if err != nil {
+			// The outputs may not be readable if the apply failed, which the
+			// plugin can still assert on.
+			t.Logf("WARNING: Failed to read the outputs for the plugin context: %s", err)
+		} else {
+			pluginContext.Outputs = outputs
+		}
+	}
+
+	if stepErr != nil {
+		errorText := stepErr.Error()
+		pluginContext.Error = &errorText
+	}
+
+	return &pluginContext, nil
+}
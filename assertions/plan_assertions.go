@@ -2,21 +2,29 @@ package assertions
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
-	"testing"
 
 	"github.com/gruntwork-io/terratest/modules/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/mitchellh/mapstructure"
 	"github.com/stretchr/testify/assert"
 )
 
 type PlanAssertions struct {
+	// Stops the Terraform commands of the step once it's reached, e.g. "10m".
+	Timeout    string
 	Assertions []Assertion
 }
 
 type PlanMetadata struct {
 	CmdOut string
 	Err    error
+	// Parsed output of `terraform show -json` for the saved plan. This is nil
+	// if the plan failed or its JSON representation could not be parsed.
+	Plan *terraform.PlanStruct
+	// Name of the test the plan belongs to.
+	TestName string
 }
 
 var ValidPlanAssertions = map[string]AssertionImplementation{
@@ -32,11 +40,45 @@ var ValidPlanAssertions = map[string]AssertionImplementation{
 		ValidateFunction: validatePlanFailsWithErrorAssertion,
 		RunFunction:      AssertPlanFailsWithError,
 	},
+
+	// Resource change asserts
+
+	"PlanResourceWillBeCreated": {
+		ValidateFunction: validatePlanResourceActionAssertion,
+		RunFunction:      AssertPlanResourceWillBeCreated,
+	},
+	"PlanResourceWillBeUpdated": {
+		ValidateFunction: validatePlanResourceActionAssertion,
+		RunFunction:      AssertPlanResourceWillBeUpdated,
+	},
+	"PlanResourceWillBeDestroyed": {
+		ValidateFunction: validatePlanResourceActionAssertion,
+		RunFunction:      AssertPlanResourceWillBeDestroyed,
+	},
+	"PlanResourceWillBeReplaced": {
+		ValidateFunction: validatePlanResourceActionAssertion,
+		RunFunction:      AssertPlanResourceWillBeReplaced,
+	},
+	"PlanAttributeEquals": {
+		ValidateFunction: validatePlanAttributeEqualsAssertion,
+		RunFunction:      AssertPlanAttributeEquals,
+	},
+
+	// Resource count asserts
+
+	"ResourcesAffected": {
+		ValidateFunction: validatePlanResourcesModified,
+		RunFunction:      AssertPlanResourcesAffected,
+	},
+	"NoResourcesAffected": {
+		ValidateFunction: validatePlanNoResourcesModified,
+		RunFunction:      AssertPlanNoResourcesAffected,
+	},
 }
 
 // ------------------------------------------------------------------------------------------------------------------------------
 
-func AssertPlanSucceeds(t *testing.T, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+func AssertPlanSucceeds(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
 	// cast stepMetadata to PlanMetadata
 	var planMetadata PlanMetadata
 	var ok bool
@@ -51,7 +93,7 @@ func AssertPlanSucceeds(t *testing.T, terraformOptions *terraform.Options, asser
 
 // ------------------------------------------------------------------------------------------------------------------------------
 
-func AssertPlanFails(t *testing.T, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+func AssertPlanFails(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
 	// cast stepMetadata to PlanMetadata
 	var planMetadata PlanMetadata
 	var ok bool
@@ -85,7 +127,7 @@ func validatePlanFailsWithErrorAssertion(assertion Assertion) error {
 	return nil
 }
 
-func AssertPlanFailsWithError(t *testing.T, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+func AssertPlanFailsWithError(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
 	var planFailsWithErrorMetadata planFailsWithErrorMetadata
 	err := mapstructure.Decode(assertion.Metadata, &planFailsWithErrorMetadata)
 	if err != nil {
@@ -103,9 +145,318 @@ func AssertPlanFailsWithError(t *testing.T, terraformOptions *terraform.Options,
 		ErrorAndSkip(t, "Terraform plan is expected to fail.")
 	}
 
-	receivedError := strings.Replace(planMetadata.Err.Error(), "\n", " ", -1)
+	receivedError := normalizeDiagnostics(planMetadata.Err.Error())
+
+	assert.Equal(t, strings.Contains(receivedError, normalizeDiagnostics(planFailsWithErrorMetadata.ErrorMessageContains)), true, "The expected error message ("+planFailsWithErrorMetadata.ErrorMessageContains+") is not contained in the error message.")
+}
+
+// ------------------------------------------------------------------------------------------------------------------------------
+
+type planResourceActionMetadata struct {
+	Address string
+}
+
+func validatePlanResourceActionAssertion(assertion Assertion) error {
+	var planResourceActionMetadata planResourceActionMetadata
+
+	err := mapstructure.Decode(assertion.Metadata, &planResourceActionMetadata)
+	if err != nil {
+		return fmt.Errorf("error decoding assertion metadata: %s", err)
+	}
+
+	if planResourceActionMetadata.Address == "" {
+		return fmt.Errorf("address is either not defined or is empty")
+	}
+
+	return nil
+}
+
+func AssertPlanResourceWillBeCreated(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+	assertPlanResourceAction(t, assertion, stepMetadata, "created", tfjson.Actions.Create)
+}
+
+func AssertPlanResourceWillBeUpdated(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+	assertPlanResourceAction(t, assertion, stepMetadata, "updated in-place", tfjson.Actions.Update)
+}
+
+func AssertPlanResourceWillBeDestroyed(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+	assertPlanResourceAction(t, assertion, stepMetadata, "destroyed", tfjson.Actions.Delete)
+}
+
+func AssertPlanResourceWillBeReplaced(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+	assertPlanResourceAction(t, assertion, stepMetadata, "replaced", tfjson.Actions.Replace)
+}
+
+func assertPlanResourceAction(
+	t TestingT,
+	assertion Assertion,
+	stepMetadata interface{},
+	expectedAction string,
+	matchesAction func(tfjson.Actions) bool) {
+	var planResourceActionMetadata planResourceActionMetadata
+	err := mapstructure.Decode(assertion.Metadata, &planResourceActionMetadata)
+	if err != nil {
+		ErrorAndSkipf(t, "Error decoding assertion metadata: %s", err)
+	}
+
+	plan := getPlanStruct(t, stepMetadata)
+	address := planResourceActionMetadata.Address
+
+	resourceChange, ok := plan.ResourceChangesMap[address]
+	if !ok || resourceChange.Change == nil {
+		ErrorAndSkipf(t, "The resource %s is expected to be %s but it is not part of the plan.", address, expectedAction)
+	}
+
+	actions := resourceChange.Change.Actions
+	if matchesAction(actions) {
+		return
+	}
+
+	message := fmt.Sprintf("The resource %s is expected to be %s but the planned actions are %v.", address, expectedAction, actions)
+	if len(resourceChange.Change.ReplacePaths) > 0 {
+		message += fmt.Sprintf(" Replacement is forced by the attribute paths %v.", resourceChange.Change.ReplacePaths)
+	}
 
-	assert.Equal(t, strings.Contains(receivedError, planFailsWithErrorMetadata.ErrorMessageContains), true, "The expected error message ("+planFailsWithErrorMetadata.ErrorMessageContains+") is not contained in the error message.")
+	ErrorAndSkip(t, message)
 }
 
 // ------------------------------------------------------------------------------------------------------------------------------
+
+type planAttributeEqualsMetadata struct {
+	Address       string
+	AttributePath string                      `mapstructure:"attribute_path"`
+	CompleteMatch bool                        `mapstructure:"complete_match"`
+	Value         map[interface{}]interface{} `mapstructure:",remain"`
+}
+
+func validatePlanAttributeEqualsAssertion(assertion Assertion) error {
+	var planAttributeEqualsMetadata planAttributeEqualsMetadata
+
+	err := mapstructure.Decode(assertion.Metadata, &planAttributeEqualsMetadata)
+	if err != nil {
+		return fmt.Errorf("error decoding assertion metadata: %s", err)
+	}
+
+	if planAttributeEqualsMetadata.Address == "" {
+		return fmt.Errorf("address is either not defined or is empty")
+	}
+
+	if planAttributeEqualsMetadata.AttributePath == "" {
+		return fmt.Errorf("attribute_path is either not defined or is empty")
+	}
+
+	val, ok := planAttributeEqualsMetadata.Value["value"]
+	if !ok {
+		return fmt.Errorf("value is not defined")
+	}
+
+	if val == nil {
+		return fmt.Errorf("value can not be empty")
+	}
+
+	for key := range planAttributeEqualsMetadata.Value {
+		if key != "value" {
+			return fmt.Errorf("unexpected key: %s", key)
+		}
+	}
+
+	return nil
+}
+
+func AssertPlanAttributeEquals(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+	var planAttributeEqualsMetadata planAttributeEqualsMetadata
+
+	err := mapstructure.Decode(assertion.Metadata, &planAttributeEqualsMetadata)
+	if err != nil {
+		ErrorAndSkipf(t, "error decoding assertion metadata: %s", err)
+	}
+
+	plan := getPlanStruct(t, stepMetadata)
+
+	// Get properties
+	address := planAttributeEqualsMetadata.Address
+	attributePath := planAttributeEqualsMetadata.AttributePath
+	expectedValue := planAttributeEqualsMetadata.Value["value"]
+
+	resourceChange, ok := plan.ResourceChangesMap[address]
+	if !ok || resourceChange.Change == nil {
+		ErrorAndSkipf(t, "The resource %s is not part of the plan.", address)
+	}
+
+	if resourceChange.Change.Actions.Delete() {
+		ErrorAndSkipf(t, "The resource %s is planned to be destroyed and has no attribute values.", address)
+	}
+
+	// Values that are only known after apply are not present in the planned
+	// values, so report them explicitly instead of as a missing attribute.
+	if isUnknownAtPath(resourceChange.Change.AfterUnknown, attributePath) {
+		ErrorAndSkipf(t, "The attribute %s of %s will only be known after apply.", attributePath, address)
+	}
+
+	attributeValue, err := getValueAtPath(resourceChange.Change.After, attributePath)
+	if err != nil {
+		ErrorAndSkipf(t, "Could not find the attribute %s of %s in the plan: %s", attributePath, address, err)
+	}
+
+	partialComparisonResult := partialDeepCompare(expectedValue, attributeValue)
+	if partialComparisonResult != nil {
+		ErrorAndSkipf(t, "The attribute %s of %s has an unexpected planned value.\n\nExpected value:\n%+v\n\nPlanned Value:\n%+v\n\nReason: %s", attributePath, address, expectedValue, attributeValue, partialComparisonResult.Error())
+	}
+
+	if !planAttributeEqualsMetadata.CompleteMatch {
+		return
+	}
+
+	fullComparisonResult := partialDeepCompare(attributeValue, expectedValue)
+	if fullComparisonResult != nil {
+		ErrorAndSkipf(t, "The attribute %s of %s has an unexpected planned value.\n\nExpected following value(s):\n%+v\n\nPlanned value:\n%+v\n\nReason: %s", attributePath, address, expectedValue, attributeValue, fullComparisonResult.Error())
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------------------------------
+
+func validatePlanResourcesModified(assertion Assertion) error {
+	var resourcesModifiedMetadata resourcesModifiedMetadata
+
+	decoderMetadata, err := decodeWithMetadata(assertion, &resourcesModifiedMetadata)
+	if err != nil {
+		return fmt.Errorf("error decoding assertion metadata: %s", err)
+	}
+
+	return validateResourceCountKeys(decoderMetadata)
+}
+
+func validatePlanNoResourcesModified(assertion Assertion) error {
+	var resourcesModifiedMetadata resourcesModifiedMetadata
+
+	decoderMetadata, err := decodeWithMetadata(assertion, &resourcesModifiedMetadata)
+	if err != nil {
+		return fmt.Errorf("error decoding assertion metadata: %s", err)
+	}
+
+	for _, key := range decoderMetadata.Keys {
+		if key != "Address" {
+			return fmt.Errorf("unexpected key: %s", key)
+		}
+
+		if resourcesModifiedMetadata.Address == "" {
+			return fmt.Errorf("address must not be empty if defined")
+		}
+	}
+
+	return nil
+}
+
+func AssertPlanResourcesAffected(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+	var resourcesModifiedMetadata resourcesModifiedMetadata
+	decoderMetadata, err := decodeWithMetadata(assertion, &resourcesModifiedMetadata)
+	if err != nil {
+		ErrorAndSkipf(t, "error while decoding assertion metadata: %s", err)
+	}
+
+	plan := getPlanStruct(t, stepMetadata)
+	resourcesCount := countPlannedResourceChanges(plan, resourcesModifiedMetadata.Address)
+
+	assertResourceCounts(t, decoderMetadata, resourcesModifiedMetadata, resourcesCount)
+}
+
+func AssertPlanNoResourcesAffected(t TestingT, terraformOptions *terraform.Options, assertion Assertion, stepMetadata interface{}) {
+	var noResourcesModifiedMetadata resourcesModifiedMetadata
+	if _, err := decodeWithMetadata(assertion, &noResourcesModifiedMetadata); err != nil {
+		ErrorAndSkipf(t, "error while decoding assertion metadata: %s", err)
+	}
+
+	plan := getPlanStruct(t, stepMetadata)
+
+	// Only the address filter is kept, all the counts are expected to be 0.
+	resourcesCount := countPlannedResourceChanges(plan, noResourcesModifiedMetadata.Address)
+	expectedCounts := resourcesModifiedMetadata{Address: noResourcesModifiedMetadata.Address}
+	allCounts := mapstructure.Metadata{Keys: []string{"Added", "Changed", "Destroyed"}}
+
+	assertResourceCounts(t, allCounts, expectedCounts, resourcesCount)
+}
+
+// Counts the planned resource changes the same way terraform does in the plan
+// summary, i.e. a replacement counts both as an addition and a destruction.
+// Only resources with an address matching addressGlob are counted if it's set.
+func countPlannedResourceChanges(plan *terraform.PlanStruct, addressGlob string) *terraform.ResourceCount {
+	resourcesCount := terraform.ResourceCount{}
+
+	for address, resourceChange := range plan.ResourceChangesMap {
+		if resourceChange.Change == nil {
+			continue
+		}
+
+		if addressGlob != "" && !matchAddressGlob(addressGlob, address) {
+			continue
+		}
+
+		actions := resourceChange.Change.Actions
+		if actions.Create() {
+			resourcesCount.Add++
+		} else if actions.Update() {
+			resourcesCount.Change++
+		} else if actions.Delete() {
+			resourcesCount.Destroy++
+		} else if actions.Replace() {
+			resourcesCount.Add++
+			resourcesCount.Destroy++
+		}
+	}
+
+	return &resourcesCount
+}
+
+// Returns whether the resource address matches the glob pattern. Only * is a
+// wildcard, matching any sequence of characters, so that the brackets and
+// quotes of indexed addresses like aws_instance.web[0] match literally.
+func matchAddressGlob(addressGlob string, address string) bool {
+	pattern := strings.ReplaceAll(regexp.QuoteMeta(addressGlob), `\*`, ".*")
+
+	return regexp.MustCompile("^" + pattern + "$").MatchString(address)
+}
+
+// Returns whether the value at the path is only known after apply according to
+// the after_unknown of a resource change. after_unknown is true for a value
+// whose parent map or list is unknown as a whole, and doesn't contain the
+// paths below it.
+func isUnknownAtPath(afterUnknown interface{}, path string) bool {
+	if afterUnknown == true {
+		return true
+	}
+
+	segments := strings.Split(path, ".")
+	for i := range segments {
+		unknown, err := getValueAtPath(afterUnknown, strings.Join(segments[:i+1], "."))
+		if err != nil {
+			return false
+		}
+
+		if unknown == true {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Retrieves the parsed plan from the step metadata. The test is failed and
+// skipped if the plan is not available, e.g. because terraform plan failed.
+func getPlanStruct(t TestingT, stepMetadata interface{}) *terraform.PlanStruct {
+	// cast stepMetadata to PlanMetadata
+	var planMetadata PlanMetadata
+	var ok bool
+	if planMetadata, ok = stepMetadata.(PlanMetadata); !ok {
+		ErrorAndSkip(t, "stepMetadata is not of type PlanMetadata")
+	}
+
+	if planMetadata.Err != nil {
+		ErrorAndSkipf(t, "Terraform plan is expected to succeed but failed with: %s", planMetadata.Err)
+	}
+
+	if planMetadata.Plan == nil {
+		ErrorAndSkip(t, "The JSON representation of the plan is not available.")
+	}
+
+	return planMetadata.Plan
+}
@@ -0,0 +1,82 @@
+package assertions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchAddressGlob(t *testing.T) {
+	tests := []struct {
+		glob     string
+		address  string
+		expected bool
+	}{
+		{"aws_s3_bucket.this", "aws_s3_bucket.this", true},
+		{"aws_s3_bucket.this", "aws_s3_bucket.that", false},
+		{"aws_s3_bucket.*", "aws_s3_bucket.this", true},
+		{"aws_s3_bucket.*", "aws_iam_role.this", false},
+		{"*", "module.storage.aws_s3_bucket.this", true},
+		{"module.storage.*", "module.storage.aws_s3_bucket.this", true},
+		{"module.storage.*", "module.other.aws_s3_bucket.this", false},
+		{"*.aws_s3_bucket.this", "module.storage.aws_s3_bucket.this", true},
+		// Only a full match counts.
+		{"aws_s3_bucket", "aws_s3_bucket.this", false},
+		{"bucket.this", "aws_s3_bucket.this", false},
+		// Dots are matched literally, not as any character.
+		{"aws_s3_bucket.this", "aws_s3_bucketXthis", false},
+		// Brackets of indexes are matched literally, not as character classes.
+		{"aws_s3_bucket.this[0]", "aws_s3_bucket.this[0]", true},
+		{"aws_s3_bucket.this[0]", "aws_s3_bucket.this0", false},
+		{"aws_s3_bucket.this[*]", "aws_s3_bucket.this[1]", true},
+		{`aws_s3_bucket.this["a"]`, `aws_s3_bucket.this["a"]`, true},
+		{`aws_s3_bucket.this["*"]`, `aws_s3_bucket.this["b"]`, true},
+		{"module.x[*].aws_s3_bucket.this", "module.x[0].aws_s3_bucket.this", true},
+		// Other regular expression metacharacters are matched literally.
+		{"aws_s3_bucket.this?", "aws_s3_bucket.this", false},
+		{"aws_s3_bucket.(this)", "aws_s3_bucket.(this)", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.glob+" "+test.address, func(t *testing.T) {
+			assert.Equal(t, test.expected, matchAddressGlob(test.glob, test.address))
+		})
+	}
+}
+
+func TestIsUnknownAtPath(t *testing.T) {
+	afterUnknown := map[string]interface{}{
+		"arn":  true,
+		"tags": map[string]interface{}{"env": false},
+		"rules": []interface{}{
+			map[string]interface{}{"id": true},
+			true,
+		},
+		"versioning": true,
+	}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"arn", true},
+		{"tags.env", false},
+		{"tags", false},
+		{"rules.0.id", true},
+		{"rules.0.name", false},
+		// The parent is unknown as a whole, so its attributes are too.
+		{"rules.1.id", true},
+		{"versioning.0.enabled", true},
+		{"missing", false},
+		{"missing.nested", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			assert.Equal(t, test.expected, isUnknownAtPath(afterUnknown, test.path))
+		})
+	}
+
+	assert.True(t, isUnknownAtPath(true, "anything"), "a resource unknown as a whole has unknown attributes")
+	assert.False(t, isUnknownAtPath(nil, "arn"))
+}
@@ -0,0 +1,178 @@
+package assertions
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// recordingT is a TestingT which records failures instead of reporting them to
+// the test, so that the runner can decide what to do with them. Logs and sub
+// tests are still passed on to the parent test until it is detached.
+type recordingT struct {
+	parent TestingT
+	// Derived from the context of the parent, and cancelled once the function
+	// running against it must stop.
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	mu       sync.Mutex
+	failed   bool
+	skipped  bool
+	detached bool
+	failures []string
+}
+
+// Runs the function against a recordingT and returns it once the function
+// returns or stops the test, e.g. with FailNow or SkipNow.
+func runRecorded(parent TestingT, f func(t TestingT)) *recordingT {
+	t, done := startRecorded(parent, f)
+	<-done
+
+	return t
+}
+
+// Starts running the function against a recordingT, and returns it with a
+// channel which is closed once the function returns or stops the test.
+func startRecorded(parent TestingT, f func(t TestingT)) (*recordingT, <-chan struct{}) {
+	ctx, cancel := context.WithCancelCause(Context(parent))
+	t := &recordingT{parent: parent, ctx: ctx, cancel: cancel}
+
+	// FailNow and SkipNow stop the goroutine they are called from, so the
+	// function runs in its own goroutine like a test does.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer cancel(nil)
+
+		f(t)
+	}()
+
+	return t, done
+}
+
+// Cancels the context of the function running against it with the given
+// cause, e.g. once it timed out.
+func (t *recordingT) stop(cause error) {
+	t.cancel(cause)
+}
+
+// Stops passing logs and sub tests on to the parent test, e.g. once the
+// function running against it didn't stop and the parent test may be done.
+func (t *recordingT) detach() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.detached = true
+}
+
+func (t *recordingT) isDetached() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.detached
+}
+
+func (t *recordingT) Name() string {
+	return t.parent.Name()
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Log(args ...any) {
+	if t.isDetached() {
+		return
+	}
+
+	t.parent.Helper()
+	t.parent.Log(args...)
+}
+
+func (t *recordingT) Logf(format string, args ...any) {
+	if t.isDetached() {
+		return
+	}
+
+	t.parent.Helper()
+	t.parent.Logf(format, args...)
+}
+
+func (t *recordingT) Fail() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.failed = true
+}
+
+func (t *recordingT) FailNow() {
+	t.Fail()
+	runtime.Goexit()
+}
+
+func (t *recordingT) Error(args ...any) {
+	t.addFailure(fmt.Sprint(args...))
+}
+
+func (t *recordingT) Errorf(format string, args ...any) {
+	t.addFailure(fmt.Sprintf(format, args...))
+}
+
+func (t *recordingT) Fatal(args ...any) {
+	t.Error(args...)
+	t.FailNow()
+}
+
+func (t *recordingT) Fatalf(format string, args ...any) {
+	t.Errorf(format, args...)
+	t.FailNow()
+}
+
+func (t *recordingT) SkipNow() {
+	t.mu.Lock()
+	t.skipped = true
+	t.mu.Unlock()
+
+	runtime.Goexit()
+}
+
+func (t *recordingT) Failed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.failed
+}
+
+func (t *recordingT) Skipped() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.skipped
+}
+
+// Sub tests can't be recorded as they need a *testing.T, so they are run as
+// sub tests of the parent test, and their failures fail the parent test.
+func (t *recordingT) Run(name string, f func(t *testing.T)) bool {
+	if t.isDetached() {
+		return false
+	}
+
+	return t.parent.Run(name, f)
+}
+
+func (t *recordingT) addFailure(message string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.failed = true
+	t.failures = append(t.failures, message)
+}
+
+// Returns the recorded failure messages.
+func (t *recordingT) Failures() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]string{}, t.failures...)
+}
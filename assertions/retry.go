@@ -0,0 +1,106 @@
+package assertions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const DEFAULT_RETRY_INTERVAL = 5 * time.Second
+
+// Retry makes an assertion retry until it passes, e.g. for eventually
+// consistent resources.
+type Retry struct {
+	// Maximum number of attempts, including the first one. Unlimited until
+	// the timeout if not set.
+	Attempts int
+	// Time to wait between attempts, e.g. "10s". Defaults to 5s.
+	Interval string
+	// No new attempt is started after the timeout, e.g. "2m".
+	Timeout string
+}
+
+func validateRetry(retry Retry) error {
+	if retry.Attempts < 0 {
+		return fmt.Errorf("retry attempts must not be negative")
+	}
+
+	if retry.Attempts == 0 && retry.Timeout == "" {
+		return fmt.Errorf("retry requires at least one of attempts or timeout")
+	}
+
+	if retry.Interval != "" {
+		if interval, err := time.ParseDuration(retry.Interval); err != nil || interval < 0 {
+			return fmt.Errorf("retry interval '%s' is not a valid duration", retry.Interval)
+		}
+	}
+
+	if retry.Timeout != "" {
+		if timeout, err := time.ParseDuration(retry.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("retry timeout '%s' is not a valid duration", retry.Timeout)
+		}
+	}
+
+	return nil
+}
+
+// Runs the function until it passes or the retry limits are reached. Failed
+// attempts are run against a recordingT and only logged, so that the test is
+// only marked as failed if the final attempt fails. No new attempt is started
+// once the context of the test is done.
+func runWithRetry(t TestingT, retry Retry, f func(t TestingT)) {
+	// The retry config is already validated.
+	interval := DEFAULT_RETRY_INTERVAL
+	if retry.Interval != "" {
+		interval, _ = time.ParseDuration(retry.Interval)
+	}
+
+	ctx := Context(t)
+
+	var deadline time.Time
+	if retry.Timeout != "" {
+		timeout, _ := time.ParseDuration(retry.Timeout)
+		deadline = time.Now().Add(timeout)
+	}
+
+	for attempt := 1; ; attempt++ {
+		isFinalAttempt := attempt == retry.Attempts ||
+			(!deadline.IsZero() && time.Now().Add(interval).After(deadline))
+		if isFinalAttempt {
+			if attempt > 1 {
+				t.Logf("INFO: Final attempt %d", attempt)
+			}
+
+			f(t)
+
+			return
+		}
+
+		recorded := runRecorded(t, f)
+		if !recorded.Failed() {
+			if attempt > 1 {
+				t.Logf("INFO: Attempt %d passed", attempt)
+			}
+
+			if recorded.Skipped() {
+				t.SkipNow()
+			}
+
+			return
+		}
+
+		failures := strings.Join(recorded.Failures(), "\n")
+		t.Logf("INFO: Attempt %d failed, retrying in %s:\n%s", attempt, interval, failures)
+
+		// No new attempt is started once the assertion must stop, e.g. because
+		// it timed out.
+		select {
+		case <-ctx.Done():
+			t.Errorf("ERROR: Stopped retrying after attempt %d as %s:\n%s", attempt, context.Cause(ctx), failures)
+
+			return
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package assertions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/schrodinger/infra-tester/utils/cmd"
+)
+
+// Matches template expressions like ${{ vars.region }} in assertion inputs.
+var templateRegexp = regexp.MustCompile(`\$\{\{(.*?)\}\}`)
+
+// TemplateData holds the values template expressions are resolved with.
+type TemplateData struct {
+	// Terraform vars of the test.
+	Vars map[string]interface{}
+	// Environment variables set for Terraform, i.e. the env_vars of the test.
+	// They take precedence over the environment of infra-tester.
+	EnvVars map[string]string
+	// Returns the Terraform outputs. If nil, output references are validated
+	// but left unresolved, e.g. during validation before anything is applied.
+	Outputs func() (map[string]interface{}, error)
+}
+
+// Returns a copy of the assertion with all the template expressions in its
+// inputs resolved. Expressions reference vars (${{ vars.name }}), outputs
+// (${{ outputs.name }}) or environment variables (${{ env.NAME }}) from the
+// env_vars of the test or the environment of infra-tester, and vars
+// and outputs support dot separated paths to nested values. An input which is
+// a single expression takes the type of the referenced value, otherwise the
+// value is formatted into the string.
+func ResolveTemplates(assertion Assertion, step string, data TemplateData) (Assertion, error) {
+	resolver := templateResolver{step: step, data: data}
+
+	resolvedMetadata, err := resolver.resolveValue(assertion.Metadata)
+	if err != nil {
+		return Assertion{}, err
+	}
+
+	resolvedAssertion := assertion
+	resolvedAssertion.Metadata = resolvedMetadata.(map[interface{}]interface{})
+
+	return resolvedAssertion, nil
+}
+
+// Resolves the template expressions of the assertion at run time, using the
+// vars of the given options and the outputs of the current state.
+func resolveAssertionTemplates(t TestingT, terraformOptions *terraform.Options, assertion Assertion, step string) (Assertion, error) {
+	return ResolveTemplates(assertion, step, TemplateData{
+		Vars:    terraformOptions.Vars,
+		EnvVars: terraformOptions.EnvVars,
+		Outputs: func() (map[string]interface{}, error) {
+			return cmd.OutputAllE(Context(t), t, terraformOptions)
+		},
+	})
+}
+
+type templateResolver struct {
+	step    string
+	data    TemplateData
+	outputs map[string]interface{}
+}
+
+func (resolver *templateResolver) resolveValue(value interface{}) (interface{}, error) {
+	switch typedValue := value.(type) {
+	case string:
+		return resolver.resolveString(typedValue)
+	case map[interface{}]interface{}:
+		resolved := make(map[interface{}]interface{}, len(typedValue))
+		for key, item := range typedValue {
+			resolvedItem, err := resolver.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+
+			resolved[key] = resolvedItem
+		}
+
+		return resolved, nil
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(typedValue))
+		for key, item := range typedValue {
+			resolvedItem, err := resolver.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+
+			resolved[key] = resolvedItem
+		}
+
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, len(typedValue))
+		for i, item := range typedValue {
+			resolvedItem, err := resolver.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+
+			resolved[i] = resolvedItem
+		}
+
+		return resolved, nil
+	default:
+		return value, nil
+	}
+}
+
+func (resolver *templateResolver) resolveString(value string) (interface{}, error) {
+	matches := templateRegexp.FindAllStringSubmatchIndex(value, -1)
+	if matches == nil {
+		return value, nil
+	}
+
+	// A single expression keeps the type of the referenced value.
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(value) {
+		resolved, ok, err := resolver.resolveReference(value[matches[0][2]:matches[0][3]])
+		if err != nil || !ok {
+			return value, err
+		}
+
+		return resolved, nil
+	}
+
+	var builder strings.Builder
+	previousEnd := 0
+	for _, match := range matches {
+		builder.WriteString(value[previousEnd:match[0]])
+		previousEnd = match[1]
+
+		resolved, ok, err := resolver.resolveReference(value[match[2]:match[3]])
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			builder.WriteString(value[match[0]:match[1]])
+
+			continue
+		}
+
+		formatted, err := formatTemplateValue(resolved)
+		if err != nil {
+			return nil, err
+		}
+
+		builder.WriteString(formatted)
+	}
+
+	builder.WriteString(value[previousEnd:])
+
+	return builder.String(), nil
+}
+
+// Resolves a single reference, e.g. "vars.region". Returns false if the
+// reference can't be resolved yet, i.e. it's an output reference during
+// validation.
+func (resolver *templateResolver) resolveReference(reference string) (interface{}, bool, error) {
+	reference = strings.TrimSpace(reference)
+
+	namespace, path, found := strings.Cut(reference, ".")
+	if !found || path == "" {
+		return nil, false, fmt.Errorf("template reference '%s' is invalid, it must be of the form vars.<name>, outputs.<name> or env.<NAME>", reference)
+	}
+
+	switch namespace {
+	case "vars":
+		value, err := getValueAtPath(resolver.data.Vars, path)
+		if err != nil {
+			return nil, false, fmt.Errorf("template reference '%s' is unknown: %s", reference, err)
+		}
+
+		return value, true, nil
+	case "env":
+		if value, ok := resolver.data.EnvVars[path]; ok {
+			return value, true, nil
+		}
+
+		value, ok := os.LookupEnv(path)
+		if !ok {
+			return nil, false, fmt.Errorf("template reference '%s' is unknown: environment variable %s is not set", reference, path)
+		}
+
+		return value, true, nil
+	case "outputs":
+		if resolver.step != "apply" {
+			return nil, false, fmt.Errorf("template reference '%s' is invalid, outputs can only be referenced in the apply step", reference)
+		}
+
+		if resolver.data.Outputs == nil {
+			return nil, false, nil
+		}
+
+		if resolver.outputs == nil {
+			outputs, err := resolver.data.Outputs()
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to get terraform outputs to resolve '%s': %s", reference, err)
+			}
+
+			resolver.outputs = outputs
+		}
+
+		value, err := getValueAtPath(resolver.outputs, path)
+		if err != nil {
+			return nil, false, fmt.Errorf("template reference '%s' is unknown: %s", reference, err)
+		}
+
+		return value, true, nil
+	default:
+		return nil, false, fmt.Errorf("template reference '%s' is unknown, only vars, outputs and env can be referenced", reference)
+	}
+}
+
+// Formats a value resolved in the middle of a string. Complex values are
+// formatted as JSON.
+func formatTemplateValue(value interface{}) (string, error) {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		formatted, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to format %+v: %s", value, err)
+		}
+
+		return string(formatted), nil
+	default:
+		return fmt.Sprintf("%v", value), nil
+	}
+}
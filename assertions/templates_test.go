@@ -0,0 +1,196 @@
+package assertions
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveTemplates(t *testing.T) {
+	t.Setenv("INFRA_TESTER_TEMPLATE_TEST", "from-environment")
+	t.Setenv("INFRA_TESTER_TEMPLATE_OVERRIDE", "from-environment")
+
+	data := TemplateData{
+		Vars: map[string]interface{}{
+			"region": "us-east-1",
+			"count":  3,
+			"tags":   map[string]interface{}{"env": "test"},
+			"zones":  []interface{}{"a", "b"},
+		},
+		EnvVars: map[string]string{"INFRA_TESTER_TEMPLATE_OVERRIDE": "from-env-vars"},
+		Outputs: func() (map[string]interface{}, error) {
+			return map[string]interface{}{"url": "https://example.com", "ports": []interface{}{80.0, 443.0}}, nil
+		},
+	}
+
+	tests := []struct {
+		name     string
+		step     string
+		value    interface{}
+		data     *TemplateData
+		expected interface{}
+		err      string
+	}{
+		{
+			name:     "strings without expressions are kept",
+			value:    "plain",
+			expected: "plain",
+		},
+		{
+			name:     "non string values are kept",
+			value:    42,
+			expected: 42,
+		},
+		{
+			name:     "a single expression keeps the type of the value",
+			value:    "${{ vars.count }}",
+			expected: 3,
+		},
+		{
+			name:     "a single expression can reference a map",
+			value:    "${{vars.tags}}",
+			expected: map[string]interface{}{"env": "test"},
+		},
+		{
+			name:     "nested values are referenced by path",
+			value:    "${{ vars.tags.env }}",
+			expected: "test",
+		},
+		{
+			name:     "list items are referenced by index",
+			value:    "${{ vars.zones.1 }}",
+			expected: "b",
+		},
+		{
+			name:     "expressions in a string are formatted into it",
+			value:    "bucket-${{ vars.region }}-${{ vars.count }}",
+			expected: "bucket-us-east-1-3",
+		},
+		{
+			name:     "complex values in a string are formatted as JSON",
+			value:    "zones: ${{ vars.zones }}",
+			expected: `zones: ["a","b"]`,
+		},
+		{
+			name:     "env_vars take precedence over the environment",
+			value:    "${{ env.INFRA_TESTER_TEMPLATE_OVERRIDE }}",
+			expected: "from-env-vars",
+		},
+		{
+			name:     "environment variables are resolved",
+			value:    "${{ env.INFRA_TESTER_TEMPLATE_TEST }}",
+			expected: "from-environment",
+		},
+		{
+			name:     "outputs are resolved in the apply step",
+			step:     "apply",
+			value:    "${{ outputs.url }}/health",
+			expected: "https://example.com/health",
+		},
+		{
+			name:     "output references are left unresolved without outputs",
+			step:     "apply",
+			value:    "${{ outputs.url }}/health",
+			data:     &TemplateData{},
+			expected: "${{ outputs.url }}/health",
+		},
+		{
+			name:     "maps and lists are resolved recursively",
+			value:    map[string]interface{}{"list": []interface{}{"${{ vars.region }}", 1}},
+			expected: map[string]interface{}{"list": []interface{}{"us-east-1", 1}},
+		},
+		{
+			name:  "outputs can't be referenced in the plan step",
+			step:  "plan",
+			value: "${{ outputs.url }}",
+			err:   "outputs can only be referenced in the apply step",
+		},
+		{
+			name:  "unknown vars are an error",
+			value: "${{ vars.missing }}",
+			err:   "template reference 'vars.missing' is unknown",
+		},
+		{
+			name:  "unset environment variables are an error",
+			value: "${{ env.INFRA_TESTER_TEMPLATE_UNSET }}",
+			err:   "environment variable INFRA_TESTER_TEMPLATE_UNSET is not set",
+		},
+		{
+			name:  "references without a path are invalid",
+			value: "${{ vars }}",
+			err:   "template reference 'vars' is invalid",
+		},
+		{
+			name:  "unknown namespaces are an error",
+			value: "${{ secrets.token }}",
+			err:   "only vars, outputs and env can be referenced",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step := test.step
+			if step == "" {
+				step = "plan"
+			}
+
+			testData := data
+			if test.data != nil {
+				testData = *test.data
+			}
+
+			assertion := Assertion{
+				Type:     "Test",
+				Metadata: map[interface{}]interface{}{"value": test.value},
+			}
+
+			resolved, err := ResolveTemplates(assertion, step, testData)
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, resolved.Metadata["value"])
+			assert.Equal(t, test.value, assertion.Metadata["value"], "the assertion must not be modified")
+		})
+	}
+}
+
+func TestResolveTemplatesGetsOutputsOnce(t *testing.T) {
+	calls := 0
+	data := TemplateData{
+		Outputs: func() (map[string]interface{}, error) {
+			calls++
+
+			return map[string]interface{}{"a": "1", "b": "2"}, nil
+		},
+	}
+
+	assertion := Assertion{Metadata: map[interface{}]interface{}{
+		"a": "${{ outputs.a }}",
+		"b": "${{ outputs.b }}",
+	}}
+
+	_, err := ResolveTemplates(assertion, "apply", data)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestResolveTemplatesOutputsError(t *testing.T) {
+	data := TemplateData{
+		Outputs: func() (map[string]interface{}, error) {
+			return nil, fmt.Errorf("no state")
+		},
+	}
+
+	assertion := Assertion{Metadata: map[interface{}]interface{}{"value": "${{ outputs.url }}"}}
+
+	_, err := ResolveTemplates(assertion, "apply", data)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to get terraform outputs to resolve 'outputs.url': no state")
+}
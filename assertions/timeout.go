@@ -0,0 +1,85 @@
+package assertions
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func validateTimeout(timeout string) error {
+	if parsed, err := time.ParseDuration(timeout); err != nil || parsed <= 0 {
+		return fmt.Errorf("timeout '%s' is not a valid duration", timeout)
+	}
+
+	return nil
+}
+
+// Time an assertion which timed out is given to stop, longer than the time
+// the commands it runs are given to exit once interrupted.
+const STOP_WAIT_DELAY = 3 * time.Minute
+
+// Runs the function and fails the test if it doesn't return within the
+// timeout, including all its retries. The function runs against a recordingT
+// whose results are passed on to the test once it returns. Once the timeout is
+// reached, the context of the function is cancelled so that its commands are
+// interrupted, and it's given STOP_WAIT_DELAY to return. A function which
+// ignores its context keeps running in the background with its results
+// ignored.
+func runWithTimeout(t TestingT, assertionName string, timeout time.Duration, f func(t TestingT)) {
+	recorded, done := startRecorded(t, f)
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		cause := fmt.Errorf("assertion '%s' timed out after %s", assertionName, timeout)
+		recorded.stop(cause)
+
+		// The commands of the assertion must not overlap with the next steps.
+		select {
+		case <-done:
+		case <-time.After(STOP_WAIT_DELAY):
+			recorded.detach()
+			t.Logf("WARNING: Assertion '%s' didn't stop within %s, it keeps running in the background", assertionName, STOP_WAIT_DELAY)
+		}
+
+		t.Errorf("ERROR: %s", cause)
+
+		return
+	}
+
+	for _, failure := range recorded.Failures() {
+		t.Error(failure)
+	}
+
+	// Failures reported with Fail or FailNow have no message.
+	if recorded.Failed() && !t.Failed() {
+		t.Fail()
+	}
+
+	if recorded.Skipped() {
+		t.SkipNow()
+	}
+}
+
+// Returns the context cleanups run with. It's derived from ctx, but only
+// cancelled STOP_WAIT_DELAY after ctx is done, e.g. once the step timed out or
+// the run was interrupted, so that cleanups still run but can't block the run.
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	cleanupCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+
+	stopAfterFunc := context.AfterFunc(ctx, func() {
+		timer := time.NewTimer(STOP_WAIT_DELAY)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			cancel(fmt.Errorf("the cleanup didn't finish within %s after %s", STOP_WAIT_DELAY, context.Cause(ctx)))
+		case <-cleanupCtx.Done():
+		}
+	})
+
+	return cleanupCtx, func() {
+		stopAfterFunc()
+		cancel(nil)
+	}
+}
@@ -1,6 +1,22 @@
 package assertions
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Joins the lines of Terraform and OpenTofu diagnostics into a single line so
+// that messages can be matched regardless of how they are wrapped. The box
+// drawing characters framing diagnostics since Terraform 0.15 are removed.
+func normalizeDiagnostics(diagnostics string) string {
+	lines := strings.Split(diagnostics, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimLeft(strings.TrimSpace(line), "│╷╵")
+	}
+
+	return strings.Join(strings.Fields(strings.Join(lines, " ")), " ")
+}
 
 func partialDeepCompare(a, b interface{}) error {
 	switch typedA := a.(type) {
@@ -128,3 +144,36 @@ func partialDeepCompareMap(typedA map[string]interface{}, b interface{}) error {
 
 	return nil
 }
+
+// Walks the given value along a dot separated attribute path and returns the
+// value found at the end of it. Numeric path segments are used as indices for
+// lists, e.g. "ingress.0.from_port".
+func getValueAtPath(value interface{}, path string) (interface{}, error) {
+	current := value
+	for _, segment := range strings.Split(path, ".") {
+		switch typedCurrent := current.(type) {
+		case map[string]interface{}:
+			next, ok := typedCurrent[segment]
+			if !ok {
+				return nil, fmt.Errorf("key %s is not present in %+v", segment, typedCurrent)
+			}
+
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil {
+				return nil, fmt.Errorf("%s is not a valid index for %+v", segment, typedCurrent)
+			}
+
+			if index < 0 || index >= len(typedCurrent) {
+				return nil, fmt.Errorf("index %d is out of range for %+v", index, typedCurrent)
+			}
+
+			current = typedCurrent[index]
+		default:
+			return nil, fmt.Errorf("can not look up %s in %+v of type %T", segment, current, current)
+		}
+	}
+
+	return current, nil
+}
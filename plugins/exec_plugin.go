@@ -0,0 +1,247 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/schrodinger/infra-tester/utils"
+	"github.com/schrodinger/infra-tester/utils/cmd"
+)
+
+// Executables with this prefix on PATH or in the plugin directories are
+// plugins, e.g. infra-tester-plugin-URLReachable provides URLReachable.
+const EXEC_PLUGIN_PREFIX = "infra-tester-plugin-"
+
+type execPluginManager struct {
+	pluginDirs    []string
+	commandRunner cmd.CommandRunner
+
+	// Guards plugins as the manager may be used from concurrently running
+	// tests.
+	mu      sync.Mutex
+	plugins map[string]string
+}
+
+// NewExecPluginManager creates a new PluginManager for plugins implemented as
+// executables, which can be written in any language. Plugins are looked up in
+// the given directories first, and then on PATH.
+func NewExecPluginManager(commandRunner cmd.CommandRunner, pluginDirs []string) PluginManager {
+	return &execPluginManager{
+		pluginDirs:    pluginDirs,
+		commandRunner: commandRunner,
+	}
+}
+
+func (p *execPluginManager) ListPlugins() (map[string]bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	plugins, err := p.findPlugins()
+	if err != nil {
+		return nil, err
+	}
+
+	availablePlugins := make(map[string]bool, len(plugins))
+	for pluginName := range plugins {
+		availablePlugins[pluginName] = true
+	}
+
+	return availablePlugins, nil
+}
+
+func (p *execPluginManager) GetPluginRunner(pluginName string) (PluginRunner, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	plugins, err := p.findPlugins()
+	if err != nil {
+		return nil, err
+	}
+
+	executable, ok := plugins[pluginName]
+	if !ok {
+		return nil, &UnknownPluginError{PluginName: pluginName}
+	}
+
+	return &execPluginRunner{
+		pluginName:    pluginName,
+		executable:    executable,
+		commandRunner: p.commandRunner,
+	}, nil
+}
+
+// Finds the plugin executables, the caller must hold the lock. If the same
+// plugin is found multiple times, the first one wins like it does for PATH.
+func (p *execPluginManager) findPlugins() (map[string]string, error) {
+	if p.plugins != nil {
+		return p.plugins, nil
+	}
+
+	for _, pluginDir := range p.pluginDirs {
+		if info, err := os.Stat(pluginDir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("plugin directory '%s' is not a directory", pluginDir)
+		}
+	}
+
+	plugins := map[string]string{}
+	dirs := append(append([]string{}, p.pluginDirs...), filepath.SplitList(os.Getenv("PATH"))...)
+	for _, dir := range dirs {
+		// Directories on PATH may not exist, so errors are ignored.
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), EXEC_PLUGIN_PREFIX) {
+				continue
+			}
+
+			// Follow symlinks, and only keep executable files. On Windows, the
+			// extension is not part of the name of the plugin.
+			path := filepath.Join(dir, entry.Name())
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+
+			name, ok := executableName(entry.Name(), info)
+			if !ok || name == PLUGIN_MANAGER_EXECUTABLE {
+				continue
+			}
+
+			pluginName := strings.TrimPrefix(name, EXEC_PLUGIN_PREFIX)
+			if _, ok := plugins[pluginName]; ok || pluginName == "" {
+				continue
+			}
+
+			plugins[pluginName] = path
+		}
+	}
+
+	p.plugins = plugins
+
+	return p.plugins, nil
+}
+
+type execPluginRunner struct {
+	pluginName    string
+	executable    string
+	commandRunner cmd.CommandRunner
+}
+
+// The request written as JSON to the stdin of the plugin executable.
+type execPluginRequest struct {
+	Action  string          `json:"action"`
+	Inputs  interface{}     `json:"inputs"`
+	State   json.RawMessage `json:"state"`
+	Context *PluginContext  `json:"context"`
+}
+
+func (p *execPluginRunner) PluginName() string {
+	return p.pluginName
+}
+
+func (p *execPluginRunner) ValidateInputs(inputs utils.GenericMappable) error {
+	res, err := p.execute(context.Background(), nil, ACTION_VALIDATE_INPUTS, inputs, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	return res.CheckErrors()
+}
+
+func (p *execPluginRunner) Run(ctx context.Context, t TestingT, inputs utils.GenericMappable, state *string, pluginContext *PluginContext) error {
+	res, err := p.execute(ctx, t, ACTION_RUN_ASSERTION, inputs, state, pluginContext)
+	if err != nil {
+		return err
+	}
+
+	return res.CheckErrors()
+}
+
+func (p *execPluginRunner) Cleanup(ctx context.Context, t TestingT, inputs utils.GenericMappable, state *string) error {
+	res, err := p.execute(ctx, t, ACTION_CLEANUP, inputs, state, nil)
+	if err != nil {
+		return err
+	}
+
+	return res.CheckErrors()
+}
+
+// Runs the plugin executable with the action as its only argument, and the
+// request as JSON on stdin. Like for the Python plugins, the inputs are the
+// inputs of the assertion, the state is the Terraform state JSON, and the
+// context is only passed when running the assertion. The output of the plugin
+// is logged to t while it runs, unless t is nil. The plugin is interrupted
+// once ctx is done.
+func (p *execPluginRunner) execute(
+	ctx context.Context,
+	t TestingT,
+	action string,
+	inputs utils.GenericMappable,
+	state *string,
+	pluginContext *PluginContext) (PluginResult, error) {
+	request := execPluginRequest{Action: action, State: json.RawMessage("null"), Context: pluginContext}
+
+	if inputs != nil {
+		// Like the Python plugin framework, plugins only get the metadata.
+		request.Inputs = inputs.ToGenericMap()["metadata"]
+	}
+
+	if state != nil && *state != "" {
+		request.State = json.RawMessage(*state)
+	}
+
+	stdin, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("error while building the request for %s: %s. "+
+			"Please raise an issue with the logs", p.pluginName, err)
+	}
+
+	return &execPluginResult{
+		pluginName: p.pluginName,
+		cmdRunnerResult: p.commandRunner.Run(ctx, cmd.Command{
+			Name:      p.executable,
+			Args:      []string{action},
+			Stdin:     bytes.NewReader(stdin),
+			Log:       t,
+			LogPrefix: fmt.Sprintf("plugin %s", p.pluginName),
+		}),
+	}, nil
+}
+
+type execPluginResult struct {
+	Error           bool    `json:"error"`
+	Message         *string `json:"message"`
+	pluginName      string
+	cmdRunnerResult cmd.CommandResult
+}
+
+func (p *execPluginResult) CheckErrors() error {
+	if err := p.cmdRunnerResult.Error(); err != nil {
+		return fmt.Errorf("error while executing plugin %s (%s): %s: %s",
+			p.pluginName,
+			p.cmdRunnerResult.ExecutedCommand(),
+			err,
+			p.cmdRunnerResult.Stderr())
+	}
+
+	if err := json.Unmarshal([]byte(p.cmdRunnerResult.Stdout()), p); err != nil {
+		return fmt.Errorf("plugin %s returned an invalid result, it must print a JSON object "+
+			"with the 'error' and 'message' keys to stdout: %s", p.pluginName, err)
+	}
+
+	if p.Error {
+		if p.Message == nil {
+			return errors.New("plugin returned an error without a message")
+		}
+
+		return errors.New(*p.Message)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+//go:build !windows
+
+package plugins
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecPluginManagerFindPlugins(t *testing.T) {
+	// A file in one of the directories, either "plugins" or "path", or a
+	// symlink to another file if target is set.
+	type file struct {
+		dir    string
+		name   string
+		mode   os.FileMode
+		target string
+	}
+
+	tests := []struct {
+		name     string
+		files    []file
+		expected map[string]string
+	}{
+		{
+			name: "executables with the prefix are plugins",
+			files: []file{
+				{dir: "plugins", name: "infra-tester-plugin-URLReachable", mode: 0755},
+				{dir: "path", name: "infra-tester-plugin-DNSResolves", mode: 0700},
+			},
+			expected: map[string]string{
+				"URLReachable": "plugins/infra-tester-plugin-URLReachable",
+				"DNSResolves":  "path/infra-tester-plugin-DNSResolves",
+			},
+		},
+		{
+			name: "files which are not executable are ignored",
+			files: []file{
+				{dir: "plugins", name: "infra-tester-plugin-URLReachable", mode: 0644},
+			},
+			expected: map[string]string{},
+		},
+		{
+			name: "executables without the prefix are ignored",
+			files: []file{
+				{dir: "plugins", name: "URLReachable", mode: 0755},
+				{dir: "path", name: "terraform", mode: 0755},
+			},
+			expected: map[string]string{},
+		},
+		{
+			name: "the plugin manager and the bare prefix are not plugins",
+			files: []file{
+				{dir: "path", name: PLUGIN_MANAGER_EXECUTABLE, mode: 0755},
+				{dir: "path", name: EXEC_PLUGIN_PREFIX, mode: 0755},
+			},
+			expected: map[string]string{},
+		},
+		{
+			name: "plugin directories take precedence over PATH",
+			files: []file{
+				{dir: "path", name: "infra-tester-plugin-URLReachable", mode: 0755},
+				{dir: "plugins", name: "infra-tester-plugin-URLReachable", mode: 0755},
+			},
+			expected: map[string]string{
+				"URLReachable": "plugins/infra-tester-plugin-URLReachable",
+			},
+		},
+		{
+			name: "symlinks to executables are followed",
+			files: []file{
+				{dir: "path", name: "url-reachable", mode: 0755},
+				{dir: "plugins", name: "infra-tester-plugin-URLReachable", target: "path/url-reachable"},
+			},
+			expected: map[string]string{
+				"URLReachable": "plugins/infra-tester-plugin-URLReachable",
+			},
+		},
+		{
+			name: "broken symlinks are ignored",
+			files: []file{
+				{dir: "plugins", name: "infra-tester-plugin-URLReachable", target: "path/missing"},
+			},
+			expected: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, dir := range []string{"plugins", "path"} {
+				require.NoError(t, os.Mkdir(filepath.Join(root, dir), 0755))
+			}
+
+			for _, f := range test.files {
+				path := filepath.Join(root, f.dir, f.name)
+				if f.target != "" {
+					require.NoError(t, os.Symlink(filepath.Join(root, f.target), path))
+				} else {
+					require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"), f.mode))
+					require.NoError(t, os.Chmod(path, f.mode))
+				}
+			}
+
+			t.Setenv("PATH", filepath.Join(root, "path")+string(os.PathListSeparator)+filepath.Join(root, "missing"))
+
+			manager := &execPluginManager{pluginDirs: []string{filepath.Join(root, "plugins")}}
+
+			plugins, err := manager.findPlugins()
+			require.NoError(t, err)
+
+			expected := map[string]string{}
+			for name, path := range test.expected {
+				expected[name] = filepath.Join(root, path)
+			}
+
+			assert.Equal(t, expected, plugins)
+		})
+	}
+}
+
+func TestExecPluginManagerFindPluginsInvalidDir(t *testing.T) {
+	manager := &execPluginManager{pluginDirs: []string{filepath.Join(t.TempDir(), "missing")}}
+
+	_, err := manager.findPlugins()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not a directory")
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package plugins
+
+import "os"
+
+// Returns the name of the executable file, and whether the file is
+// executable by anyone.
+func executableName(name string, info os.FileInfo) (string, bool) {
+	return name, info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+}
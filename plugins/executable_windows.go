@@ -0,0 +1,32 @@
+//go:build windows
+
+package plugins
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Returns the name of the executable file without its extension, and whether
+// the file is executable, i.e. its extension is listed in PATHEXT like for
+// exec.LookPath.
+func executableName(name string, info os.FileInfo) (string, bool) {
+	if !info.Mode().IsRegular() {
+		return "", false
+	}
+
+	pathExt := os.Getenv("PATHEXT")
+	if pathExt == "" {
+		pathExt = ".com;.exe;.bat;.cmd"
+	}
+
+	ext := filepath.Ext(name)
+	for _, executableExt := range filepath.SplitList(pathExt) {
+		if executableExt != "" && strings.EqualFold(ext, executableExt) {
+			return strings.TrimSuffix(name, ext), true
+		}
+	}
+
+	return "", false
+}
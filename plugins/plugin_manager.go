@@ -1,8 +1,10 @@
 package plugins
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/schrodinger/infra-tester/utils/cmd"
 )
@@ -16,17 +18,46 @@ type PluginManager interface {
 	GetPluginRunner(pluginName string) (PluginRunner, error)
 }
 
+// UnknownPluginError is returned when a PluginRunner is requested for a
+// plugin that is not available.
+type UnknownPluginError struct {
+	PluginName string
+}
+
+func (e *UnknownPluginError) Error() string {
+	return fmt.Sprintf("plugin '%s' is not available, make sure the plugin is installed", e.PluginName)
+}
+
 type pipPluginManager struct {
 	pipPath          string
 	availablePlugins map[string]bool
 	commandRunner    cmd.CommandRunner
-	pluginRunner     PluginRunner
+	// The configured transport, resolved against the installed plugin
+	// framework before the first plugin runner is created.
+	transport string
+	framework *pluginFramework
+
+	// Guards availablePlugins, framework and pluginRunners as the
+	// manager may be used from concurrently running tests.
+	mu            sync.Mutex
+	pluginRunners map[string]PluginRunner
 }
 
 // NewPipPluginManager creates a new PluginManager that uses pip3 to manage
 // plugins. As of now infra-tester-plugin-manager is used to just list the
 // available plugins.
 func NewPipPluginManager(commandRunner cmd.CommandRunner) (PluginManager, error) {
+	return NewPipPluginManagerWithTransport(commandRunner, PLUGIN_TRANSPORT_AUTO)
+}
+
+// NewPipPluginManagerWithTransport is the same as NewPipPluginManager, but the
+// plugins get their inputs, state and context with the given transport, one of
+// the PLUGIN_TRANSPORT_* constants.
+func NewPipPluginManagerWithTransport(commandRunner cmd.CommandRunner, transport string) (PluginManager, error) {
+	if err := ValidatePluginTransport(transport); err != nil {
+		return nil, fmt.Errorf("error while creating plugin manager: %s", err)
+	}
+
 	// Get the path to pip3 executable.
 	pipPath, err := commandRunner.LookPath("pip3")
 	if err != nil {
@@ -39,6 +70,8 @@ func NewPipPluginManager(commandRunner cmd.CommandRunner) (PluginManager, error)
 	pluginManager := pipPluginManager{
 		pipPath:       pipPath,
 		commandRunner: commandRunner,
+		transport:     transport,
+		pluginRunners: map[string]PluginRunner{},
 	}
 
 	if err != nil {
@@ -49,6 +82,14 @@ func NewPipPluginManager(commandRunner cmd.CommandRunner) (PluginManager, error)
 }
 
 func (p *pipPluginManager) ListPlugins() (map[string]bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.listPlugins()
+}
+
+// Lists the available plugins, the caller must hold the lock.
+func (p *pipPluginManager) listPlugins() (map[string]bool, error) {
 	if p.availablePlugins != nil {
 		return p.availablePlugins, nil
 	}
@@ -65,13 +106,40 @@ func (p *pipPluginManager) ListPlugins() (map[string]bool, error) {
 }
 
 func (p *pipPluginManager) GetPluginRunner(pluginName string) (PluginRunner, error) {
-	if p.pluginRunner != nil {
-		return p.pluginRunner, nil
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pluginRunner, ok := p.pluginRunners[pluginName]; ok {
+		return pluginRunner, nil
+	}
+
+	availablePlugins, err := p.listPlugins()
+	if err != nil {
+		return nil, err
 	}
 
-	p.pluginRunner = NewPluginRunner(p.commandRunner, pluginName)
+	if !availablePlugins[pluginName] {
+		return nil, &UnknownPluginError{PluginName: pluginName}
+	}
 
-	return p.pluginRunner, nil
+	if p.framework == nil {
+		framework, err := resolvePluginFramework(p.commandRunner, p.transport)
+		if err != nil {
+			return nil, err
+		}
+
+		p.framework = &framework
+	}
+
+	pluginRunner := &pipPluginRunner{
+		pluginName:  pluginName,
+		cmdRunner:   p.commandRunner,
+		transport:   p.framework.transport,
+		passContext: p.framework.context,
+	}
+	p.pluginRunners[pluginName] = pluginRunner
+
+	return pluginRunner, nil
 }
 
 // Parses the output of infra-tester-plugin-manager --list and returns a list
@@ -91,3 +159,48 @@ func parseAvailablePlugins(out string) map[string]bool {
 
 	return availablePlugins
 }
+
+type multiPluginManager struct {
+	pluginManagers []PluginManager
+}
+
+// NewMultiPluginManager creates a new PluginManager which combines the given
+// plugin managers. If a plugin is provided by multiple managers, the first
+// one wins.
+func NewMultiPluginManager(pluginManagers ...PluginManager) PluginManager {
+	return &multiPluginManager{
+		pluginManagers: pluginManagers,
+	}
+}
+
+func (p *multiPluginManager) ListPlugins() (map[string]bool, error) {
+	availablePlugins := map[string]bool{}
+	for _, pluginManager := range p.pluginManagers {
+		plugins, err := pluginManager.ListPlugins()
+		if err != nil {
+			return nil, err
+		}
+
+		for pluginName := range plugins {
+			availablePlugins[pluginName] = true
+		}
+	}
+
+	return availablePlugins, nil
+}
+
+func (p *multiPluginManager) GetPluginRunner(pluginName string) (PluginRunner, error) {
+	for _, pluginManager := range p.pluginManagers {
+		pluginRunner, err := pluginManager.GetPluginRunner(pluginName)
+		if err == nil {
+			return pluginRunner, nil
+		}
+
+		var unknownPluginError *UnknownPluginError
+		if !errors.As(err, &unknownPluginError) {
+			return nil, err
+		}
+	}
+
+	return nil, &UnknownPluginError{PluginName: pluginName}
+}
@@ -3,7 +3,6 @@ package plugins
 import (
 	"errors"
 	"fmt"
-	"testing"
 
 	"github.com/schrodinger/infra-tester/utils"
 	"github.com/schrodinger/infra-tester/utils/cmd"
@@ -17,7 +16,6 @@ const (
 
 type PluginResult interface {
 	CheckErrors() error
-	Logf(t *testing.T)
 }
 
 type pipPluginRunnerResult struct {
@@ -71,18 +69,17 @@ func (p *pipPluginRunnerResult) CheckErrors() error {
 		return nil
 	}
 
+	// The plugin didn't exit on its own, e.g. it was stopped because it timed
+	// out, or it couldn't be started.
+	if exitCode == -1 {
+		return fmt.Errorf("error while executing plugin (%s): %s: %s",
+			executedCommand,
+			p.cmdRunnerResult.Error(),
+			stderr+stdout)
+	}
+
 	return fmt.Errorf("received unknown exit code '%d' while executing plugin (%s): %s",
 		exitCode,
 		executedCommand,
 		stderr+stdout)
 }
-
-func (p *pipPluginRunnerResult) Logf(t *testing.T) {
-	stdout := p.cmdRunnerResult.Stdout()
-	stderr := p.cmdRunnerResult.Stderr()
-
-	t.Log("INFO: Plugin stdout")
-	t.Log(stdout)
-	t.Log("INFO: Plugin stderr")
-	t.Log(stderr)
-}
@@ -1,8 +1,9 @@
 package plugins
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"testing"
 
 	"github.com/schrodinger/infra-tester/utils"
 	"github.com/schrodinger/infra-tester/utils/cmd"
@@ -14,6 +15,31 @@ const (
 	ACTION_CLEANUP         = "cleanup"
 )
 
+// TestingT is the subset of *testing.T used by plugin runners to log the
+// output of plugins while they run.
+type TestingT interface {
+	Log(args ...any)
+	Logf(format string, args ...any)
+}
+
+// PluginContext describes the step an assertion runs in. It is passed to the
+// plugin when running the assertion, so that plugins can assert on the plan in
+// the plan step, and on the state in the apply step.
+type PluginContext struct {
+	// Either "plan" or "apply".
+	Step     string                 `json:"step"`
+	TestName string                 `json:"test_name"`
+	Vars     map[string]interface{} `json:"vars"`
+	// The JSON representation of the plan, only set in the plan step if the
+	// plan succeeded.
+	Plan json.RawMessage `json:"plan"`
+	// The JSON representation of the state, only set in the apply step.
+	State   json.RawMessage        `json:"state"`
+	Outputs map[string]interface{} `json:"outputs"`
+	// The error of the plan or apply, nil if it succeeded.
+	Error *string `json:"error"`
+}
+
 type PluginRunner interface {
 	// Retrieves the name of the plugin.
 	PluginName() string
@@ -21,15 +47,19 @@ type PluginRunner interface {
 	// Validates the inputs for the plugin.
 	ValidateInputs(inputs utils.GenericMappable) error
 
-	// Runs the plugin.
-	Run(t *testing.T,
+	// Runs the plugin, which is interrupted once ctx is done. The plugin
+	// context is optional.
+	Run(ctx context.Context,
+		t TestingT,
 		inputs utils.GenericMappable,
-		state *string) error
-
-	// Executes cleanup for the plugin. This function
-	// should ideally be deferred in the same function
-	// where the plugin is run.
-	Cleanup(t *testing.T,
+		state *string,
+		pluginContext *PluginContext) error
+
+	// Executes cleanup for the plugin, which is interrupted once ctx is done.
+	// This function should ideally be deferred in the same function where the
+	// plugin is run.
+	Cleanup(ctx context.Context,
+		t TestingT,
 		inputs utils.GenericMappable,
 		state *string) error
 }
@@ -37,14 +67,23 @@ type PluginRunner interface {
 type pipPluginRunner struct {
 	pluginName string
 	cmdRunner  cmd.CommandRunner
+	transport  string
+	// Whether the plugin framework accepts the context, which is left out
+	// otherwise.
+	passContext bool
 }
 
 // Creates a new PluginRunner for the given plugin. PluginRunner can be used
-// to validate inputs, run or execute cleanup for a given plugin.
+// to validate inputs, run or execute cleanup for a given plugin. The inputs
+// and state are passed as arguments, which all versions of the plugin
+// framework support. The context is not passed, as versions of the framework
+// without the --context flag reject it, use a PluginManager to get runners
+// passing the context when the installed framework supports it.
 func NewPluginRunner(commandRunner cmd.CommandRunner, pluginName string) PluginRunner {
 	return &pipPluginRunner{
 		pluginName: pluginName,
 		cmdRunner:  commandRunner,
+		transport:  PLUGIN_TRANSPORT_ARGV,
 	}
 }
 
@@ -54,7 +93,7 @@ func (p *pipPluginRunner) PluginName() string {
 
 func (p *pipPluginRunner) ValidateInputs(
 	inputs utils.GenericMappable) error {
-	res, err := p.execute(PLUGIN_RUNNER_EXECUTABLE, ACTION_VALIDATE_INPUTS, inputs, nil)
+	res, err := p.execute(context.Background(), nil, PLUGIN_RUNNER_EXECUTABLE, ACTION_VALIDATE_INPUTS, inputs, nil, nil)
 	if err != nil {
 		return err
 	}
@@ -63,24 +102,25 @@ func (p *pipPluginRunner) ValidateInputs(
 }
 
 func (p *pipPluginRunner) Run(
-	t *testing.T,
+	ctx context.Context,
+	t TestingT,
 	inputs utils.GenericMappable,
-	state *string) error {
-	res, err := p.execute(PLUGIN_RUNNER_EXECUTABLE, ACTION_RUN_ASSERTION, inputs, state)
+	state *string,
+	pluginContext *PluginContext) error {
+	res, err := p.execute(ctx, t, PLUGIN_RUNNER_EXECUTABLE, ACTION_RUN_ASSERTION, inputs, state, pluginContext)
 	if err != nil {
 		return err
 	}
 
-	res.Logf(t)
-
 	return res.CheckErrors()
 }
 
 func (p *pipPluginRunner) Cleanup(
-	t *testing.T,
+	ctx context.Context,
+	t TestingT,
 	inputs utils.GenericMappable,
 	state *string) error {
-	res, err := p.execute(PLUGIN_RUNNER_EXECUTABLE, ACTION_CLEANUP, inputs, state)
+	res, err := p.execute(ctx, t, PLUGIN_RUNNER_EXECUTABLE, ACTION_CLEANUP, inputs, state, nil)
 	if err != nil {
 		return err
 	}
@@ -88,51 +128,72 @@ func (p *pipPluginRunner) Cleanup(
 	return res.CheckErrors()
 }
 
-// Executes the plugin with the given command and args. The error returned
-// by this function does not indicate if the command ran successfully or not.
-// The error returned by this function indicates if there's utils.GenericMappable error with the
+// Executes the plugin with the given command, passing the inputs, state and
+// context with the transport of the runner. The plugin is interrupted once ctx
+// is done. The output of the plugin is logged to t while it runs, unless t is
+// nil. The error returned by this function
+// does not indicate if the command ran successfully or not. The error returned
+// by this function indicates if there's utils.GenericMappable error with the
 // inputs to the plugin.
 func (p *pipPluginRunner) execute(
+	ctx context.Context,
+	t TestingT,
 	command string,
 	action string,
 	inputs utils.GenericMappable,
-	state *string) (PluginResult, error) {
+	state *string,
+	pluginContext *PluginContext) (PluginResult, error) {
 
-	args, err := p.buildArgs(action, inputs, state)
+	payload, err := p.buildPayload(inputs, state, pluginContext)
 	if err != nil {
 		return nil, fmt.Errorf("error while building args for %s: %s ."+
 			"Please raise an issue with the logs", p.pluginName, err)
 	}
 
+	pluginCommand := cmd.Command{
+		Name:      command,
+		Args:      []string{"--name", p.pluginName, "--action", action},
+		Log:       t,
+		LogPrefix: fmt.Sprintf("plugin %s", p.pluginName),
+	}
+
+	cleanup, err := payload.addTo(&pluginCommand, p.transport)
+	defer cleanup()
+	if err != nil {
+		return nil, fmt.Errorf("error while passing the payload to %s: %s", p.pluginName, err)
+	}
+
 	return &pipPluginRunnerResult{
-		cmdRunnerResult: p.cmdRunner.RunCommand(command, args...),
+		cmdRunnerResult: p.cmdRunner.Run(ctx, pluginCommand),
 	}, nil
 }
 
-func (p *pipPluginRunner) buildArgs(
-	action string,
+func (p *pipPluginRunner) buildPayload(
 	inputs utils.GenericMappable,
-	state *string) ([]string, error) {
-
-	args := []string{
-		"--name", p.pluginName,
-		"--action", action,
-	}
+	state *string,
+	pluginContext *PluginContext) (pluginPayload, error) {
+	// state is optional and already a JSON string.
+	payload := pluginPayload{state: state}
 
 	if inputs != nil {
 		jsonInputs, err := utils.ToJSON(inputs)
 
 		if err != nil {
-			return nil, fmt.Errorf("error while converting inputs %#v to json: %s", inputs, err)
+			return payload, fmt.Errorf("error while converting inputs %#v to json: %s", inputs, err)
 		}
 
-		args = append(args, "--inputs", jsonInputs)
+		payload.inputs = &jsonInputs
 	}
 
-	// state is optional and already a JSON string.
-	if state != nil {
-		args = append(args, "--state", *state)
+	if pluginContext != nil && p.passContext {
+		jsonContext, err := json.Marshal(pluginContext)
+		if err != nil {
+			return payload, fmt.Errorf("error while converting context to json: %s", err)
+		}
+
+		contextString := string(jsonContext)
+		payload.context = &contextString
 	}
 
-	return args, nil
+	return payload, nil
 }
@@ -0,0 +1,161 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/schrodinger/infra-tester/utils/cmd"
+)
+
+// How the inputs, state and context are passed to Python plugins.
+const (
+	// Stdin if the installed plugin framework supports it, argv otherwise.
+	PLUGIN_TRANSPORT_AUTO = "auto"
+	// Only the inputs and state as command line arguments, supported by all
+	// plugin framework versions. The context is not passed, as it would put
+	// the state or plan on the command line a second time. Large states may
+	// exceed the argument size limit of the system, and the arguments show up
+	// in process listings.
+	PLUGIN_TRANSPORT_ARGV = "argv"
+	// A single JSON object written to stdin.
+	PLUGIN_TRANSPORT_STDIN = "stdin"
+	// Files only readable by the current user, removed once the plugin exits.
+	PLUGIN_TRANSPORT_FILE = "file"
+)
+
+// Flag of infra-tester-run-plugin which tells whether it supports the stdin
+// and file transports.
+const stdinTransportFlag = "--stdin"
+
+// Flag of infra-tester-run-plugin which tells whether it accepts the context
+// of the step. Versions of the framework without it reject unknown arguments.
+const contextFlag = "--context"
+
+func ValidatePluginTransport(transport string) error {
+	switch transport {
+	case PLUGIN_TRANSPORT_AUTO, PLUGIN_TRANSPORT_ARGV, PLUGIN_TRANSPORT_STDIN, PLUGIN_TRANSPORT_FILE:
+		return nil
+	}
+
+	return fmt.Errorf("plugin transport '%s' is invalid, it must be one of '%s', '%s', '%s' or '%s'", transport,
+		PLUGIN_TRANSPORT_AUTO, PLUGIN_TRANSPORT_ARGV, PLUGIN_TRANSPORT_STDIN, PLUGIN_TRANSPORT_FILE)
+}
+
+// pluginFramework describes how plugins are run with the installed plugin
+// framework.
+type pluginFramework struct {
+	transport string
+	// Whether the framework accepts the context of the step.
+	context bool
+}
+
+// Resolves the transport to use with the installed plugin framework, and
+// whether it accepts the context, from the help of infra-tester-run-plugin.
+// Versions of the framework without the stdin and file transports only
+// support argv.
+func resolvePluginFramework(commandRunner cmd.CommandRunner, transport string) (pluginFramework, error) {
+	res := commandRunner.RunCommand(PLUGIN_RUNNER_EXECUTABLE, "--help")
+	if err := res.Error(); err != nil {
+		return pluginFramework{}, fmt.Errorf("error while checking the features of %s: %s", PLUGIN_RUNNER_EXECUTABLE, err)
+	}
+
+	help := res.Stdout()
+	framework := pluginFramework{transport: transport, context: strings.Contains(help, contextFlag)}
+	supported := strings.Contains(help, stdinTransportFlag)
+
+	switch {
+	case transport == PLUGIN_TRANSPORT_ARGV:
+	case transport == PLUGIN_TRANSPORT_AUTO && supported:
+		framework.transport = PLUGIN_TRANSPORT_STDIN
+	case transport == PLUGIN_TRANSPORT_AUTO:
+		framework.transport = PLUGIN_TRANSPORT_ARGV
+	case !supported:
+		return pluginFramework{}, fmt.Errorf("the installed %s package does not support the '%s' plugin transport, "+
+			"please upgrade it or use the '%s' transport", PLUGIN_FRAMEWORK_PACKAGE, transport, PLUGIN_TRANSPORT_ARGV)
+	}
+
+	return framework, nil
+}
+
+// pluginPayload holds the JSON representations of the inputs, state and
+// context passed to a plugin. Each of them is optional.
+type pluginPayload struct {
+	inputs  *string
+	state   *string
+	context *string
+}
+
+// Adds the payload to the command according to the transport. The returned
+// function removes the files created for the file transport, and must be
+// called once the plugin exited.
+func (p pluginPayload) addTo(command *cmd.Command, transport string) (func(), error) {
+	cleanup := func() {}
+
+	switch transport {
+	case PLUGIN_TRANSPORT_STDIN:
+		stdin := map[string]json.RawMessage{}
+		for _, field := range p.fields() {
+			// An empty state can't be embedded, plugins get null instead.
+			if field.value != nil && *field.value != "" {
+				stdin[field.name] = json.RawMessage(*field.value)
+			}
+		}
+
+		jsonStdin, err := json.Marshal(stdin)
+		if err != nil {
+			return cleanup, fmt.Errorf("error while converting the payload to json: %s", err)
+		}
+
+		command.Args = append(command.Args, stdinTransportFlag)
+		command.Stdin = strings.NewReader(string(jsonStdin))
+	case PLUGIN_TRANSPORT_FILE:
+		// The directory is only accessible by the current user, as the state
+		// may contain secrets.
+		dir, err := os.MkdirTemp("", "infra-tester-plugin-")
+		if err != nil {
+			return cleanup, fmt.Errorf("error while creating a directory for the payload: %s", err)
+		}
+
+		cleanup = func() { os.RemoveAll(dir) }
+
+		for _, field := range p.fields() {
+			if field.value == nil {
+				continue
+			}
+
+			path := filepath.Join(dir, field.name+".json")
+			if err := os.WriteFile(path, []byte(*field.value), 0600); err != nil {
+				return cleanup, fmt.Errorf("error while writing the %s of the payload: %s", field.name, err)
+			}
+
+			command.Args = append(command.Args, fmt.Sprintf("--%s-file", field.name), path)
+		}
+	default:
+		for _, field := range p.fields() {
+			// The context contains the state or plan, which are already large
+			// enough as a single argument.
+			if field.value != nil && field.name != "context" {
+				command.Args = append(command.Args, fmt.Sprintf("--%s", field.name), *field.value)
+			}
+		}
+	}
+
+	return cleanup, nil
+}
+
+type payloadField struct {
+	name  string
+	value *string
+}
+
+// Returns the fields of the payload in the order they are passed.
+func (p pluginPayload) fields() []payloadField {
+	return []payloadField{
+		{name: "inputs", value: p.inputs},
+		{name: "state", value: p.state},
+		{name: "context", value: p.context},
+	}
+}
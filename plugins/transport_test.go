@@ -0,0 +1,117 @@
+package plugins
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/schrodinger/infra-tester/utils/cmd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stringPointer(s string) *string {
+	return &s
+}
+
+func TestPluginPayloadAddTo(t *testing.T) {
+	tests := []struct {
+		name      string
+		payload   pluginPayload
+		transport string
+		args      []string
+		stdin     string
+	}{
+		{
+			name:      "argv passes the inputs and state as arguments",
+			payload:   pluginPayload{inputs: stringPointer(`{"a":1}`), state: stringPointer(`{"s":2}`)},
+			transport: PLUGIN_TRANSPORT_ARGV,
+			args:      []string{"run", "--inputs", `{"a":1}`, "--state", `{"s":2}`},
+		},
+		{
+			name:      "argv doesn't pass the context",
+			payload:   pluginPayload{inputs: stringPointer(`{"a":1}`), context: stringPointer(`{"step":"plan"}`)},
+			transport: PLUGIN_TRANSPORT_ARGV,
+			args:      []string{"run", "--inputs", `{"a":1}`},
+		},
+		{
+			name:      "argv passes an empty state",
+			payload:   pluginPayload{inputs: stringPointer(`{}`), state: stringPointer("")},
+			transport: PLUGIN_TRANSPORT_ARGV,
+			args:      []string{"run", "--inputs", `{}`, "--state", ""},
+		},
+		{
+			name: "stdin passes all the fields as a single object",
+			payload: pluginPayload{
+				inputs:  stringPointer(`{"a":1}`),
+				state:   stringPointer(`{"s":2}`),
+				context: stringPointer(`{"step":"plan"}`),
+			},
+			transport: PLUGIN_TRANSPORT_STDIN,
+			args:      []string{"run", "--stdin"},
+			stdin:     `{"context":{"step":"plan"},"inputs":{"a":1},"state":{"s":2}}`,
+		},
+		{
+			name:      "stdin leaves out empty and missing fields",
+			payload:   pluginPayload{inputs: stringPointer(`{"a":1}`), state: stringPointer("")},
+			transport: PLUGIN_TRANSPORT_STDIN,
+			args:      []string{"run", "--stdin"},
+			stdin:     `{"inputs":{"a":1}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			command := cmd.Command{Name: "plugin", Args: []string{"run"}}
+
+			cleanup, err := test.payload.addTo(&command, test.transport)
+			require.NoError(t, err)
+			defer cleanup()
+
+			assert.Equal(t, test.args, command.Args)
+
+			if test.stdin == "" {
+				assert.Nil(t, command.Stdin)
+
+				return
+			}
+
+			stdin, err := io.ReadAll(command.Stdin)
+			require.NoError(t, err)
+			assert.JSONEq(t, test.stdin, string(stdin))
+		})
+	}
+}
+
+func TestPluginPayloadAddToFile(t *testing.T) {
+	payload := pluginPayload{inputs: stringPointer(`{"a":1}`), state: stringPointer("")}
+	command := cmd.Command{Name: "plugin", Args: []string{"run"}}
+
+	cleanup, err := payload.addTo(&command, PLUGIN_TRANSPORT_FILE)
+	require.NoError(t, err)
+
+	require.Len(t, command.Args, 5)
+	assert.Equal(t, []string{"run", "--inputs-file"}, command.Args[:2])
+	assert.Equal(t, "--state-file", command.Args[3])
+
+	inputsPath := command.Args[2]
+	dir := filepath.Dir(inputsPath)
+	assert.Equal(t, dir, filepath.Dir(command.Args[4]))
+
+	files := map[string]string{inputsPath: `{"a":1}`, command.Args[4]: ""}
+	for path, expected := range files {
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(content))
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "the payload may contain secrets")
+	}
+
+	cleanup()
+
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err), "the files must be removed by the cleanup")
+}
@@ -1,5 +1,5 @@
 import sys
-from typing import Any, Dict, Union
+from typing import Any, Dict, Optional, Union
 
 
 class BaseAssertionPlugin(object):
@@ -37,7 +37,10 @@ class BaseAssertionPlugin(object):
         )
 
     def run_assertion(
-        self, inputs: Dict[Any, Any], state: Dict[Any, Any]
+        self,
+        inputs: Dict[Any, Any],
+        state: Dict[Any, Any],
+        context: Optional[Dict[Any, Any]] = None,
     ) -> Union[str, None]:
         """
         This method should contain the logic to run the assertion
@@ -58,6 +61,13 @@ class BaseAssertionPlugin(object):
             state (Dict[str, object]): The current Terraform state
             as a dictionary.
 
+            context (Optional[Dict[str, object]]): The context of the
+            step the assertion runs in, with the keys 'step',
+            'test_name', 'vars', 'plan' (plan step only), 'state'
+            (apply step only), 'outputs' (apply step only) and
+            'error'. Plugins may leave this argument out of their
+            signature if they don't need it.
+
         Raises:
             NotImplementedError: If the plugin does not implement
             this method.
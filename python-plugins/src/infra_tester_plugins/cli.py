@@ -1,11 +1,12 @@
 import argparse
 import contextlib
+import inspect
 import json
 import os
 import sys
 from enum import IntEnum
 from importlib.metadata import entry_points
-from typing import Callable, Union
+from typing import Callable, Dict, Optional, Union
 
 from . import PLUGIN_GROUP, BaseAssertionPlugin
 from .result import PluginResult
@@ -59,6 +60,72 @@ def ensure_python_version() -> None:
         sys.exit(1)
 
 
+def accepts_context(run_assertion: Callable[..., Union[str, None]]) -> bool:
+    """
+    Check if the run_assertion method of a plugin accepts the context
+    argument. Plugins written before the context was added only accept
+    the inputs and the state.
+
+    Args:
+        run_assertion (Callable): The bound run_assertion method.
+
+    Returns:
+        bool: True if the method accepts a third positional argument.
+    """
+    parameters = inspect.signature(run_assertion).parameters.values()
+    positional = [
+        p
+        for p in parameters
+        if p.kind in (p.POSITIONAL_ONLY, p.POSITIONAL_OR_KEYWORD)
+    ]
+
+    return len(positional) >= 3 or any(
+        p.kind == p.VAR_POSITIONAL for p in parameters
+    )
+
+
+def read_payload(args: argparse.Namespace) -> Dict[str, Optional[str]]:
+    """
+    Read the inputs, state and context passed by infra-tester as JSON
+    strings. Older versions of infra-tester pass them as arguments, newer
+    ones over stdin or in files, so that large states don't exceed the
+    argument size limit and secrets don't show up in process listings.
+
+    Args:
+        args (argparse.Namespace): The parsed CLI arguments.
+
+    Raises:
+        OSError: If a file can't be read.
+        ValueError: If stdin is not a JSON object.
+
+    Returns:
+        Dict[str, Optional[str]]: The inputs, state and context by name,
+        None if not passed.
+    """
+    payload = {
+        "inputs": args.inputs,
+        "state": args.state,
+        "context": args.context,
+    }
+
+    if args.stdin:
+        data = json.load(sys.stdin)
+        if not isinstance(data, dict):
+            raise ValueError("stdin must contain a JSON object")
+
+        for key in payload:
+            if data.get(key) is not None:
+                payload[key] = json.dumps(data[key])
+
+    for key in payload:
+        path = getattr(args, f"{key}_file")
+        if path is not None:
+            with open(path, encoding="utf-8") as f:
+                payload[key] = f.read()
+
+    return payload
+
+
 def assertion_cli() -> Union[int, None]:
     """Entry point for the assertion CLI.
 
@@ -93,17 +160,55 @@ def assertion_cli() -> Union[int, None]:
         help="Action to run.",
     )
 
-    parser.add_argument(
+    inputs_group = parser.add_mutually_exclusive_group()
+    inputs_group.add_argument(
         "-i", "--inputs", type=str, default=None, help="Inputs in JSON."
     )
+    inputs_group.add_argument(
+        "--inputs-file",
+        type=str,
+        default=None,
+        help="Path to a file containing the inputs in JSON.",
+    )
 
-    parser.add_argument(
+    state_group = parser.add_mutually_exclusive_group()
+    state_group.add_argument(
         "-s",
         "--state",
         type=str,
         default=None,
         help="Terraform state in JSON.",
     )
+    state_group.add_argument(
+        "--state-file",
+        type=str,
+        default=None,
+        help="Path to a file containing the Terraform state in JSON.",
+    )
+
+    context_group = parser.add_mutually_exclusive_group()
+    context_group.add_argument(
+        "-c",
+        "--context",
+        type=str,
+        default=None,
+        help="Context of the step the assertion runs in, in JSON.",
+    )
+    context_group.add_argument(
+        "--context-file",
+        type=str,
+        default=None,
+        help="Path to a file containing the context in JSON.",
+    )
+
+    parser.add_argument(
+        "--stdin",
+        action="store_true",
+        help=(
+            "Read the inputs, state and context from stdin, as a JSON "
+            "object with the 'inputs', 'state' and 'context' keys."
+        ),
+    )
 
     args = parser.parse_args()
 
@@ -144,7 +249,22 @@ def assertion_cli() -> Union[int, None]:
     return_value = None
 
     try:
-        inputs = json.loads(args.inputs) if args.inputs is not None else None
+        payload = read_payload(args)
+    except (OSError, ValueError) as e:
+        print(
+            "ERROR: (infra-tester-plugins) ",
+            f"Failure while reading the payload: {e}.",
+        )
+
+        # Exit with a non-zero exit code to indicate failure.
+        return int(ExitCodes.INVALID_INPUT)
+
+    try:
+        inputs = (
+            json.loads(payload["inputs"])
+            if payload["inputs"] is not None
+            else None
+        )
     except json.JSONDecodeError as e:
         print(
             "ERROR: (infra-tester-plugins) ",
@@ -169,7 +289,11 @@ def assertion_cli() -> Union[int, None]:
     inputs = inputs["metadata"]
 
     try:
-        state = json.loads(args.state) if args.state is not None else None
+        state = (
+            json.loads(payload["state"])
+            if payload["state"] is not None
+            else None
+        )
     except json.JSONDecodeError as e:
         print(
             "ERROR: (infra-tester-plugins) ",
@@ -179,6 +303,21 @@ def assertion_cli() -> Union[int, None]:
         # Exit with a non-zero exit code to indicate failure.
         return int(ExitCodes.INVALID_INPUT)
 
+    try:
+        context = (
+            json.loads(payload["context"])
+            if payload["context"] is not None
+            else None
+        )
+    except json.JSONDecodeError as e:
+        print(
+            "ERROR: (infra-tester-plugins) ",
+            f"Failure while parsing context: {e}.",
+        )
+
+        # Exit with a non-zero exit code to indicate failure.
+        return int(ExitCodes.INVALID_INPUT)
+
     # We want to redirect stdout to stderr so that the output of the
     # plugin is not captured by the CLI. This is because infra-tester
     # communicates with the plugin via JSON. If the plugin prints
@@ -199,7 +338,12 @@ def assertion_cli() -> Union[int, None]:
             if args.action == "validate_inputs":
                 return_value = assertion.validate_inputs(inputs)
             elif args.action == "run_assertion":
-                return_value = assertion.run_assertion(inputs, state)
+                if accepts_context(assertion.run_assertion):
+                    return_value = assertion.run_assertion(
+                        inputs, state, context
+                    )
+                else:
+                    return_value = assertion.run_assertion(inputs, state)
             elif args.action == "cleanup":
                 assertion.cleanup(inputs, state)
             else:
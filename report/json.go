@@ -0,0 +1,74 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Version of the JSON report schema. It must be incremented on any
+// backwards incompatible change to the schema.
+const JSON_SCHEMA_VERSION = 1
+
+type jsonReport struct {
+	SchemaVersion int          `json:"schema_version"`
+	TestPlans     []jsonResult `json:"test_plans"`
+}
+
+type jsonResult struct {
+	Kind            Kind         `json:"kind"`
+	Name            string       `json:"name"`
+	Type            string       `json:"type,omitempty"`
+	Outcome         Outcome      `json:"outcome"`
+	StartedAt       time.Time    `json:"started_at"`
+	DurationSeconds float64      `json:"duration_seconds"`
+	Failures        []string     `json:"failures"`
+	Output          string       `json:"output,omitempty"`
+	Children        []jsonResult `json:"children"`
+}
+
+func toJSONResult(r *Result) jsonResult {
+	jsonRes := jsonResult{
+		Kind:            r.Kind,
+		Name:            r.Name,
+		Type:            r.Type,
+		Outcome:         r.Outcome,
+		StartedAt:       r.StartedAt,
+		DurationSeconds: r.Duration.Seconds(),
+		Failures:        append([]string{}, r.Failures...),
+		Output:          r.Output,
+		Children:        []jsonResult{},
+	}
+
+	for _, child := range r.Children {
+		jsonRes.Children = append(jsonRes.Children, toJSONResult(child))
+	}
+
+	return jsonRes
+}
+
+// Writes the results of the test plans of the run as JSON to the given path.
+func WriteJSON(run *Run, path string) error {
+	report := jsonReport{
+		SchemaVersion: JSON_SCHEMA_VERSION,
+		TestPlans:     []jsonResult{},
+	}
+
+	for _, testPlanResult := range run.results() {
+		testPlanResult.mu.Lock()
+		report.TestPlans = append(report.TestPlans, toJSONResult(testPlanResult))
+		testPlanResult.mu.Unlock()
+	}
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error while marshalling JSON report: %s", err)
+	}
+
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		return fmt.Errorf("error while writing JSON report to %s: %s", path, err)
+	}
+
+	return nil
+}
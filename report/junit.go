@@ -0,0 +1,206 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Time       string           `xml:"time,attr"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+	SystemOut string          `xml:"system-out,omitempty"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message  string `xml:"message,attr"`
+	Contents string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+func junitSeconds(r *Result) string {
+	return fmt.Sprintf("%.3f", r.Duration.Seconds())
+}
+
+// Builds a test case for the given result. Failures are only reported on the
+// test case if the result has failure messages of its own, failures of the
+// children are reported on their own test cases. The output of steps is not
+// attached to their test cases, but once to the test suite.
+func newJUnitTestCase(r *Result, className string) junitTestCase {
+	testCase := junitTestCase{
+		Name:      r.Name,
+		ClassName: className,
+		Time:      junitSeconds(r),
+	}
+
+	if r.Kind != KIND_STEP {
+		testCase.SystemOut = r.Output
+	}
+
+	if (r.Outcome == FAILED || r.Outcome == INTERRUPTED) && (len(r.Failures) > 0 || len(r.Children) == 0) {
+		message := "failed"
+		if len(r.Failures) > 0 {
+			message = strings.TrimSpace(r.Failures[0])
+		}
+
+		testCase.Failure = &junitFailure{
+			Message:  message,
+			Contents: strings.Join(r.Failures, "\n"),
+		}
+	} else if r.Outcome == SKIPPED {
+		testCase.Skipped = &junitSkipped{}
+	}
+
+	return testCase
+}
+
+// Each test is reported as a test suite and each assertion as a test case.
+// Steps and tests are reported as test cases too if they failed on their own,
+// e.g. because terraform apply failed without any assertions, or if they don't
+// have any children. The output of the steps is attached to the test suite, so
+// that it's only stored once however many assertions the steps have.
+func toJUnitTestSuite(testPlan *Result, test *Result) junitTestSuite {
+	testSuite := junitTestSuite{
+		Name:      testPlan.Name + "/" + test.Name,
+		Time:      junitSeconds(test),
+		Timestamp: test.StartedAt.Format("2006-01-02T15:04:05"),
+	}
+
+	if len(test.Failures) > 0 || len(test.Children) == 0 {
+		testSuite.TestCases = append(testSuite.TestCases, newJUnitTestCase(test, testSuite.Name))
+	}
+
+	var outputs []string
+	for _, step := range test.Children {
+		className := testSuite.Name + "/" + step.Name
+		if len(step.Failures) > 0 || len(step.Children) == 0 {
+			testSuite.TestCases = append(testSuite.TestCases, newJUnitTestCase(step, className))
+		}
+
+		for _, assertion := range step.Children {
+			testSuite.TestCases = append(testSuite.TestCases, newJUnitTestCase(assertion, className))
+		}
+
+		if step.Output != "" {
+			outputs = append(outputs, fmt.Sprintf("=== %s ===\n%s", step.Name, step.Output))
+		}
+	}
+
+	testSuite.SystemOut = strings.Join(outputs, "\n")
+
+	for _, testCase := range testSuite.TestCases {
+		testSuite.Tests++
+		if testCase.Failure != nil {
+			testSuite.Failures++
+		} else if testCase.Skipped != nil {
+			testSuite.Skipped++
+		}
+	}
+
+	return testSuite
+}
+
+// Returns the test suites of the test plan.
+func toJUnitTestSuites(testPlanResult *Result) []junitTestSuite {
+	testPlanResult.mu.Lock()
+	defer testPlanResult.mu.Unlock()
+
+	testSuites := []junitTestSuite{}
+
+	// Failures of the test plan itself, e.g. a failed validation, are reported
+	// in a test suite of their own.
+	if len(testPlanResult.Failures) > 0 {
+		testSuites = append(testSuites, junitTestSuite{
+			Name:      testPlanResult.Name,
+			Tests:     1,
+			Failures:  1,
+			Time:      junitSeconds(testPlanResult),
+			Timestamp: testPlanResult.StartedAt.Format("2006-01-02T15:04:05"),
+			TestCases: []junitTestCase{newJUnitTestCase(testPlanResult, testPlanResult.Name)},
+		})
+	}
+
+	for _, child := range testPlanResult.Children {
+		if child.Kind == KIND_TEST {
+			testSuites = append(testSuites, toJUnitTestSuite(testPlanResult, child))
+		} else {
+			// Steps of the test plan itself, e.g. terraform init.
+			testSuites = append(testSuites, toJUnitTestSuite(testPlanResult, &Result{
+				Kind:      KIND_TEST,
+				Name:      child.Name,
+				Outcome:   child.Outcome,
+				StartedAt: child.StartedAt,
+				Duration:  child.Duration,
+				Children:  []*Result{child},
+			}))
+		}
+	}
+
+	return testSuites
+}
+
+// Writes the results of the test plans of the run as JUnit XML to the given
+// path.
+func WriteJUnit(run *Run, path string) error {
+	testSuites := junitTestSuites{}
+
+	var names []string
+	var duration time.Duration
+	for _, testPlanResult := range run.results() {
+		testSuites.TestSuites = append(testSuites.TestSuites, toJUnitTestSuites(testPlanResult)...)
+
+		testPlanResult.mu.Lock()
+		names = append(names, testPlanResult.Name)
+		duration += testPlanResult.Duration
+		testPlanResult.mu.Unlock()
+	}
+
+	testSuites.Name = strings.Join(names, ", ")
+	testSuites.Time = fmt.Sprintf("%.3f", duration.Seconds())
+
+	for _, testSuite := range testSuites.TestSuites {
+		testSuites.Tests += testSuite.Tests
+		testSuites.Failures += testSuite.Failures
+		testSuites.Skipped += testSuite.Skipped
+	}
+
+	out, err := xml.MarshalIndent(testSuites, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error while marshalling JUnit report: %s", err)
+	}
+
+	if err := os.WriteFile(path, append([]byte(xml.Header), out...), 0644); err != nil {
+		return fmt.Errorf("error while writing JUnit report to %s: %s", path, err)
+	}
+
+	return nil
+}
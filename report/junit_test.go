@@ -0,0 +1,229 @@
+package report
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Summary of a test suite which leaves out the times.
+type junitSuiteSummary struct {
+	Name      string
+	Tests     int
+	Failures  int
+	Skipped   int
+	SystemOut string
+	// Formatted as "<classname> <name> <outcome>[: <failure message>]".
+	TestCases []string
+}
+
+func summarizeJUnitTestSuites(testSuites []junitTestSuite) []junitSuiteSummary {
+	summaries := []junitSuiteSummary{}
+	for _, testSuite := range testSuites {
+		summary := junitSuiteSummary{
+			Name:      testSuite.Name,
+			Tests:     testSuite.Tests,
+			Failures:  testSuite.Failures,
+			Skipped:   testSuite.Skipped,
+			SystemOut: testSuite.SystemOut,
+		}
+
+		for _, testCase := range testSuite.TestCases {
+			outcome := "passed"
+			if testCase.Failure != nil {
+				outcome = "failed: " + testCase.Failure.Message
+			} else if testCase.Skipped != nil {
+				outcome = "skipped"
+			}
+
+			summary.TestCases = append(summary.TestCases, fmt.Sprintf("%s %s %s", testCase.ClassName, testCase.Name, outcome))
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	return summaries
+}
+
+func TestToJUnitTestSuites(t *testing.T) {
+	startedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	result := func(kind Kind, name string, outcome Outcome, children ...*Result) *Result {
+		return &Result{Kind: kind, Name: name, Outcome: outcome, StartedAt: startedAt, Duration: time.Second, Children: children}
+	}
+
+	withFailures := func(r *Result, failures ...string) *Result {
+		r.Failures = failures
+
+		return r
+	}
+
+	withOutput := func(r *Result, output string) *Result {
+		r.Output = output
+
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		children []*Result
+		failures []string
+		expected []junitSuiteSummary
+	}{
+		{
+			name: "each test is a test suite and each assertion a test case",
+			children: []*Result{
+				result(KIND_TEST, "Bucket", PASSED,
+					result(KIND_STEP, "Plan", PASSED,
+						result(KIND_ASSERTION, "PlanSucceeds", PASSED),
+						result(KIND_ASSERTION, "ResourceCount", PASSED))),
+			},
+			expected: []junitSuiteSummary{{
+				Name:  "Storage/Bucket",
+				Tests: 2,
+				TestCases: []string{
+					"Storage/Bucket/Plan PlanSucceeds passed",
+					"Storage/Bucket/Plan ResourceCount passed",
+				},
+			}},
+		},
+		{
+			name: "failed assertions are failed test cases with their first failure as message",
+			children: []*Result{
+				result(KIND_TEST, "Bucket", FAILED,
+					result(KIND_STEP, "Plan", FAILED,
+						withFailures(result(KIND_ASSERTION, "PlanSucceeds", FAILED), " plan failed ", "exit code 1"))),
+			},
+			expected: []junitSuiteSummary{{
+				Name:      "Storage/Bucket",
+				Tests:     1,
+				Failures:  1,
+				TestCases: []string{"Storage/Bucket/Plan PlanSucceeds failed: plan failed"},
+			}},
+		},
+		{
+			name: "steps failing on their own are test cases next to their assertions",
+			children: []*Result{
+				result(KIND_TEST, "Bucket", FAILED,
+					withFailures(result(KIND_STEP, "Apply", FAILED,
+						result(KIND_ASSERTION, "ApplySucceeds", PASSED)), "apply failed")),
+			},
+			expected: []junitSuiteSummary{{
+				Name:     "Storage/Bucket",
+				Tests:    2,
+				Failures: 1,
+				TestCases: []string{
+					"Storage/Bucket/Apply Apply failed: apply failed",
+					"Storage/Bucket/Apply ApplySucceeds passed",
+				},
+			}},
+		},
+		{
+			name: "failed steps without assertions are failed test cases",
+			children: []*Result{
+				result(KIND_TEST, "Bucket", FAILED,
+					result(KIND_STEP, "Destroy", FAILED)),
+			},
+			expected: []junitSuiteSummary{{
+				Name:      "Storage/Bucket",
+				Tests:     1,
+				Failures:  1,
+				TestCases: []string{"Storage/Bucket/Destroy Destroy failed: failed"},
+			}},
+		},
+		{
+			name: "skipped tests without steps are skipped test cases",
+			children: []*Result{
+				result(KIND_TEST, "Bucket", SKIPPED),
+			},
+			expected: []junitSuiteSummary{{
+				Name:      "Storage/Bucket",
+				Tests:     1,
+				Skipped:   1,
+				TestCases: []string{"Storage/Bucket Bucket skipped"},
+			}},
+		},
+		{
+			name: "interrupted tests are failed test cases",
+			children: []*Result{
+				result(KIND_TEST, "Bucket", INTERRUPTED),
+			},
+			expected: []junitSuiteSummary{{
+				Name:      "Storage/Bucket",
+				Tests:     1,
+				Failures:  1,
+				TestCases: []string{"Storage/Bucket Bucket failed: failed"},
+			}},
+		},
+		{
+			name: "the output of the steps is attached once to the test suite",
+			children: []*Result{
+				result(KIND_TEST, "Bucket", PASSED,
+					withOutput(result(KIND_STEP, "Plan", PASSED,
+						result(KIND_ASSERTION, "PlanSucceeds", PASSED),
+						result(KIND_ASSERTION, "ResourceCount", PASSED)), "plan output"),
+					withOutput(result(KIND_STEP, "Apply", PASSED,
+						result(KIND_ASSERTION, "ApplySucceeds", PASSED)), "apply output")),
+			},
+			expected: []junitSuiteSummary{{
+				Name:      "Storage/Bucket",
+				Tests:     3,
+				SystemOut: "=== Plan ===\nplan output\n=== Apply ===\napply output",
+				TestCases: []string{
+					"Storage/Bucket/Plan PlanSucceeds passed",
+					"Storage/Bucket/Plan ResourceCount passed",
+					"Storage/Bucket/Apply ApplySucceeds passed",
+				},
+			}},
+		},
+		{
+			name: "steps of the test plan are test suites of their own",
+			children: []*Result{
+				result(KIND_TEST, "Bucket", PASSED,
+					result(KIND_STEP, "Plan", PASSED,
+						result(KIND_ASSERTION, "PlanSucceeds", PASSED))),
+				withOutput(result(KIND_STEP, "Destroy", PASSED), "destroy output"),
+			},
+			expected: []junitSuiteSummary{
+				{
+					Name:      "Storage/Bucket",
+					Tests:     1,
+					TestCases: []string{"Storage/Bucket/Plan PlanSucceeds passed"},
+				},
+				{
+					Name:      "Storage/Destroy",
+					Tests:     1,
+					SystemOut: "=== Destroy ===\ndestroy output",
+					TestCases: []string{"Storage/Destroy/Destroy Destroy passed"},
+				},
+			},
+		},
+		{
+			name:     "failures of the test plan are a test suite of their own",
+			failures: []string{"invalid config"},
+			expected: []junitSuiteSummary{{
+				Name:      "Storage",
+				Tests:     1,
+				Failures:  1,
+				TestCases: []string{"Storage Storage failed: invalid config"},
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testPlanResult := NewTestPlanResult("Storage")
+			testPlanResult.Outcome = PASSED
+			if len(test.failures) > 0 {
+				testPlanResult.Outcome = FAILED
+			}
+
+			testPlanResult.Failures = test.failures
+			testPlanResult.Children = test.children
+
+			assert.Equal(t, test.expected, summarizeJUnitTestSuites(toJUnitTestSuites(testPlanResult)))
+		})
+	}
+}
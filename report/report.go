@@ -0,0 +1,230 @@
+package report
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type Outcome string
+
+const (
+	PASSED  Outcome = "passed"
+	FAILED  Outcome = "failed"
+	SKIPPED Outcome = "skipped"
+	// The run was stopped early by a signal, e.g. when a CI job is cancelled.
+	INTERRUPTED Outcome = "interrupted"
+)
+
+type Kind string
+
+const (
+	KIND_TEST_PLAN Kind = "test_plan"
+	KIND_TEST      Kind = "test"
+	KIND_STEP      Kind = "step"
+	KIND_ASSERTION Kind = "assertion"
+)
+
+// Result records the outcome of a test plan, test, step or assertion. Results
+// form a tree with the test plan at the root. All the results of a tree share
+// a lock so that they can be recorded from concurrently running tests.
+type Result struct {
+	Kind      Kind
+	Name      string
+	Type      string
+	Outcome   Outcome
+	StartedAt time.Time
+	Duration  time.Duration
+	Failures  []string
+	Output    string
+	Children  []*Result
+
+	mu *sync.Mutex
+}
+
+// Creates the root result for the test plan with the given name.
+func NewTestPlanResult(name string) *Result {
+	return &Result{
+		Kind:      KIND_TEST_PLAN,
+		Name:      name,
+		StartedAt: time.Now(),
+		mu:        &sync.Mutex{},
+	}
+}
+
+// Run records the results of the test plans run together, one per config
+// file. Failures before the test plans run, e.g. an invalid config, are
+// recorded on the Setup result, which is only reported if it failed or if no
+// test plan started.
+type Run struct {
+	Setup *Result
+
+	mu        sync.Mutex
+	testPlans []*Result
+}
+
+func NewRun() *Run {
+	return &Run{Setup: NewTestPlanResult("Tests")}
+}
+
+// Starts recording the result of the test plan with the given name.
+func (r *Run) StartTestPlan(name string) *Result {
+	testPlanResult := NewTestPlanResult(name)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.testPlans = append(r.testPlans, testPlanResult)
+
+	return testPlanResult
+}
+
+// Returns the results of the test plans started so far.
+func (r *Run) TestPlans() []*Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*Result{}, r.testPlans...)
+}
+
+// Returns the results to report, in the order they started.
+func (r *Run) results() []*Result {
+	testPlans := r.TestPlans()
+
+	r.Setup.mu.Lock()
+	setupFailed := len(r.Setup.Failures) > 0
+	r.Setup.mu.Unlock()
+
+	if setupFailed || len(testPlans) == 0 {
+		return append([]*Result{r.Setup}, testPlans...)
+	}
+
+	return testPlans
+}
+
+// Starts recording a child result of the given kind.
+func (r *Result) Start(kind Kind, name string) *Result {
+	return r.start(kind, name, "")
+}
+
+// Starts recording the result of an assertion of the given type.
+func (r *Result) StartAssertion(name string, assertionType string) *Result {
+	return r.start(KIND_ASSERTION, name, assertionType)
+}
+
+// Adds the child while holding the lock, so that it's complete once reports
+// can see it, e.g. while they are written after an interrupt.
+func (r *Result) start(kind Kind, name string, assertionType string) *Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	child := &Result{
+		Kind:      kind,
+		Name:      name,
+		Type:      assertionType,
+		StartedAt: time.Now(),
+		mu:        r.mu,
+	}
+	r.Children = append(r.Children, child)
+
+	return child
+}
+
+// Records the outcome and the duration from the state of the given test. It
+// should be deferred right after the result is started, so that the outcome is
+// recorded even if the test is skipped or stopped early.
+func (r *Result) Finish(t *testing.T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.finish(t)
+}
+
+// Same as Finish, but only if the outcome is not recorded yet, e.g. when the
+// run exits before the test of the result returned.
+func (r *Result) FinishIfRunning(t *testing.T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Outcome == "" {
+		r.finish(t)
+	}
+}
+
+func (r *Result) finish(t *testing.T) {
+	r.Duration = time.Since(r.StartedAt)
+
+	if t.Failed() {
+		r.Outcome = FAILED
+	} else if t.Skipped() {
+		r.Outcome = SKIPPED
+	} else {
+		r.Outcome = PASSED
+	}
+}
+
+// Overrides the outcome recorded by Finish, e.g. to record an interrupted run.
+func (r *Result) SetOutcome(outcome Outcome) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Outcome = outcome
+}
+
+// Records the captured output of a command, e.g. terraform plan.
+func (r *Result) SetOutput(output string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Output = output
+}
+
+func (r *Result) AddFailure(message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Failures = append(r.Failures, message)
+}
+
+// T wraps the given test so that every failure reported through it is
+// recorded on the result as well.
+func (r *Result) T(t *testing.T) *T {
+	return &T{T: t, result: r}
+}
+
+// T is a *testing.T that records failure messages on a Result.
+type T struct {
+	*testing.T
+	result *Result
+}
+
+// Sub wraps the given sub test of t, so that its failures are recorded on the
+// result of t as well.
+func (t *T) Sub(sub *testing.T) *T {
+	return &T{T: sub, result: t.result}
+}
+
+func (t *T) Error(args ...any) {
+	t.Helper()
+	t.result.AddFailure(fmt.Sprint(args...))
+	t.T.Error(args...)
+}
+
+func (t *T) Errorf(format string, args ...any) {
+	t.Helper()
+	t.result.AddFailure(fmt.Sprintf(format, args...))
+	t.T.Errorf(format, args...)
+}
+
+func (t *T) Fatal(args ...any) {
+	t.Helper()
+	t.result.AddFailure(fmt.Sprint(args...))
+	t.T.Fatal(args...)
+}
+
+func (t *T) Fatalf(format string, args ...any) {
+	t.Helper()
+	t.result.AddFailure(fmt.Sprintf(format, args...))
+	t.T.Fatalf(format, args...)
+}
@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v3"
+)
+
+const DEFAULT_CONFIG_FILE = ".infra-tester-config.yaml"
+
+// stringSliceFlag is a flag.Value that collects the values of a flag that can
+// be specified multiple times.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+
+	return nil
+}
+
+// Expands the given config file paths and glob patterns into a list of unique
+// config file paths. Every pattern must match at least one file.
+func resolveConfigPaths(patterns []string) ([]string, error) {
+	configPaths := []string{}
+	seenPaths := map[string]bool{}
+
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config path pattern '%s': %v", pattern, err)
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no config files found for '%s'", pattern)
+		}
+
+		for _, match := range matches {
+			if seenPaths[match] {
+				continue
+			}
+
+			seenPaths[match] = true
+			configPaths = append(configPaths, match)
+		}
+	}
+
+	return configPaths, nil
+}
+
+// Reads the test plans from all the config files matching the given patterns.
+// Each config file is a test plan of its own, with its own settings. Test plan
+// and test names must be unique across the files, as the results are reported
+// and tests are selected by name.
+func getTests(patterns []string) ([]TestPlan, error) {
+	configPaths, err := resolveConfigPaths(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	testPlans := make([]TestPlan, 0, len(configPaths))
+	testPlanSources := map[string]string{}
+	testSources := map[string]string{}
+	for _, configPath := range configPaths {
+		testPlan, err := readTestPlan(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config '%s': %v", configPath, err)
+		}
+
+		if source, ok := testPlanSources[testPlan.Name]; ok {
+			return nil, fmt.Errorf("test plan name '%s' in '%s' is already used in '%s' - test plans with same name are not allowed", testPlan.Name, configPath, source)
+		}
+
+		testPlanSources[testPlan.Name] = configPath
+
+		// Expand matrix tests before validation so that every generated test
+		// is validated.
+		testPlan.Tests, err = expandMatrixTests(testPlan.Tests)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config '%s': %v", configPath, err)
+		}
+
+		// Duplicates within a file are reported by the validation of the file.
+		fileTests := map[string]bool{}
+		for _, test := range testPlan.Tests {
+			if source, ok := testSources[test.Name]; ok && !fileTests[test.Name] {
+				return nil, fmt.Errorf("test name '%s' in '%s' is already used in '%s' - tests with same name are not allowed", test.Name, configPath, source)
+			}
+
+			fileTests[test.Name] = true
+		}
+
+		for name := range fileTests {
+			testSources[name] = configPath
+		}
+
+		testPlans = append(testPlans, testPlan)
+	}
+
+	return testPlans, nil
+}
+
+func readTestPlan(configPath string) (TestPlan, error) {
+	yamlConfig, err := os.ReadFile(configPath)
+	if err != nil {
+		return TestPlan{}, fmt.Errorf("failed to read yaml config: %v", err)
+	}
+
+	var mapStruct map[string]interface{}
+	err = yaml.Unmarshal(yamlConfig, &mapStruct)
+	if err != nil {
+		return TestPlan{}, fmt.Errorf("failed to unmarshal yaml config: %v", err)
+	}
+
+	var config Config
+	err = mapstructure.Decode(mapStruct, &config)
+	if err != nil {
+		return TestPlan{}, fmt.Errorf("failed to decode map structure: %v", err)
+	}
+
+	config.TestPlan.configPath = configPath
+
+	return config.TestPlan, nil
+}
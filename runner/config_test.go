@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTests(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs map[string]string
+		plans   []string
+		err     string
+	}{
+		{
+			name: "each config file is a test plan",
+			configs: map[string]string{
+				"a.yaml": "test_plan:\n  name: A\n  tests:\n    - name: First\n",
+				"b.yaml": "test_plan:\n  name: B\n  tests:\n    - name: Second\n",
+			},
+			plans: []string{"A", "B"},
+		},
+		{
+			name: "test plan names must be unique across the files",
+			configs: map[string]string{
+				"a.yaml": "test_plan:\n  name: A\n  tests:\n    - name: First\n",
+				"b.yaml": "test_plan:\n  name: A\n  tests:\n    - name: Second\n",
+			},
+			err: "test plan name 'A'",
+		},
+		{
+			name: "test names must be unique across the files",
+			configs: map[string]string{
+				"a.yaml": "test_plan:\n  name: A\n  tests:\n    - name: Same\n",
+				"b.yaml": "test_plan:\n  name: B\n  tests:\n    - name: Same\n",
+			},
+			err: "test name 'Same'",
+		},
+		{
+			name: "test names generated by matrix tests must be unique across the files",
+			configs: map[string]string{
+				"a.yaml": "test_plan:\n  name: A\n  tests:\n    - name: Bucket(region=us)\n",
+				"b.yaml": "test_plan:\n  name: B\n  tests:\n    - name: Bucket\n      matrix:\n        region: [us]\n",
+			},
+			err: "test name 'Bucket(region=us)'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range test.configs {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+			}
+
+			testPlans, err := getTests([]string{filepath.Join(dir, "*.yaml")})
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			names := []string{}
+			for _, testPlan := range testPlans {
+				names = append(names, testPlan.Name)
+			}
+
+			assert.Equal(t, test.plans, names)
+		})
+	}
+}
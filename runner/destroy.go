@@ -0,0 +1,159 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/schrodinger/infra-tester/report"
+	"github.com/schrodinger/infra-tester/utils/cmd"
+)
+
+const DEFAULT_DESTROY_INTERVAL = 10 * time.Second
+
+// Destroy configures the destroy run at the end of the test plan, and at the
+// end of every test running in an isolated working directory.
+type Destroy struct {
+	// Maximum number of attempts, including the first one. Defaults to 1, or
+	// to retrying until the timeout if a timeout is set.
+	Attempts int
+	// Time to wait between attempts, e.g. "30s". Defaults to 10s.
+	Interval string
+	// Stops the running attempt and doesn't start new ones once the timeout
+	// is reached, e.g. "10m".
+	Timeout string
+	// Whether to check that the state is empty after the destroy. Defaults to
+	// true.
+	CheckState *bool `mapstructure:"check_state"`
+}
+
+func validateDestroy(destroy Destroy) error {
+	if destroy.Attempts < 0 {
+		return fmt.Errorf("destroy attempts must not be negative")
+	}
+
+	if destroy.Interval != "" {
+		if interval, err := time.ParseDuration(destroy.Interval); err != nil || interval < 0 {
+			return fmt.Errorf("destroy interval '%s' is not a valid duration", destroy.Interval)
+		}
+	}
+
+	if destroy.Timeout != "" {
+		if timeout, err := time.ParseDuration(destroy.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("destroy timeout '%s' is not a valid duration", destroy.Timeout)
+		}
+	}
+
+	return nil
+}
+
+// Prepares the destroy of the resources managed by the given options, and
+// returns the function running it as a "Destroy" sub test, which must be
+// deferred. A panicking test stops the program before deferred functions of
+// its parents run, so the destroy then runs as part of the cleanup of t
+// instead, as sub tests can't be started during cleanup.
+func deferDestroy(t *testing.T, terraformOptions *terraform.Options, testPlan TestPlan, result *report.Result) func() {
+	var started atomic.Bool
+
+	t.Cleanup(func() {
+		if started.Load() {
+			return
+		}
+
+		t.Log("WARNING: The tests did not finish, destroying the resources as part of the cleanup")
+		destroy(result.T(t), terraformOptions, testPlan)
+	})
+
+	return func() {
+		started.Store(true)
+
+		t.Run("Destroy", func(t *testing.T) {
+			stepResult := result.Start(report.KIND_STEP, "Destroy")
+			defer stepResult.Finish(t)
+
+			destroy(stepResult.T(t), terraformOptions, testPlan)
+		})
+	}
+}
+
+// Runs terraform destroy with destroy_vars if provided, retrying it according
+// to the destroy config of the test plan, and checks that no resources are
+// left in the state afterwards. It doesn't stop the test on failure, as it may
+// run as part of the cleanup of a panicking test.
+func destroy(t *report.T, terraformOptions *terraform.Options, testPlan TestPlan) {
+	t.Helper()
+
+	// Set terraform vars to destroy vars if they are provided
+	if testPlan.DestroyVars != nil {
+		t.Log("Using destroy_vars for destroy")
+		terraformOptions.Vars = testPlan.DestroyVars
+	}
+
+	t.Logf("INFO: Using vars for destroy: %s", formatVars(terraformOptions.Vars, testPlan.SensitiveVars))
+
+	// The destroy config is already validated.
+	config := testPlan.Destroy
+	interval := DEFAULT_DESTROY_INTERVAL
+	if config.Interval != "" {
+		interval, _ = time.ParseDuration(config.Interval)
+	}
+
+	// Destroys are not stopped when the run is interrupted, they run during the
+	// grace period.
+	ctx, cancel := withTimeout(context.Background(), config.Timeout, "the destroy")
+	defer cancel()
+
+	deadline, _ := ctx.Deadline()
+
+	for attempt := 1; ; attempt++ {
+		_, err := destroyE(ctx, t, terraformOptions)
+		if err == nil {
+			break
+		}
+
+		// Without attempts, only the timeout limits the attempts.
+		attemptsLeft := attempt < config.Attempts || (config.Attempts == 0 && !deadline.IsZero())
+		timeLeft := deadline.IsZero() || !time.Now().Add(interval).After(deadline)
+		if !attemptsLeft || !timeLeft {
+			t.Errorf("ERROR: Failure during terraform destroy after %d attempt(s): %s", attempt, err)
+
+			return
+		}
+
+		t.Logf("WARNING: Attempt %d of terraform destroy failed, retrying in %s: %s", attempt, interval, err)
+
+		select {
+		case <-ctx.Done():
+			t.Errorf("ERROR: Failure during terraform destroy after %d attempt(s) as %s: %s", attempt, context.Cause(ctx), err)
+
+			return
+		case <-time.After(interval):
+		}
+	}
+
+	if config.CheckState != nil && !*config.CheckState {
+		return
+	}
+
+	out, err := cmd.RunTerraformCommandAndGetStdoutE(ctx, t, terraformOptions, "state", "list")
+	if err != nil {
+		t.Errorf("ERROR: Failed to list the resources left in the state after destroy: %s", err)
+
+		return
+	}
+
+	var leftovers []string
+	for _, line := range strings.Split(out, "\n") {
+		if address := strings.TrimSpace(line); address != "" {
+			leftovers = append(leftovers, address)
+		}
+	}
+
+	if len(leftovers) > 0 {
+		t.Errorf("ERROR: %d resource(s) are left in the state after destroy:\n%s", len(leftovers), strings.Join(leftovers, "\n"))
+	}
+}
@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// testFilter selects the tests and steps to run from the command line flags.
+type testFilter struct {
+	namePatterns stringSliceFlag
+	includeTags  stringSliceFlag
+	excludeTags  stringSliceFlag
+	step         string
+}
+
+func (f *testFilter) validate() error {
+	for _, pattern := range f.namePatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid test name pattern '%s': %v", pattern, err)
+		}
+	}
+
+	if f.step != "" && f.step != "plan" && f.step != "apply" {
+		return fmt.Errorf("step '%s' is invalid, it must be either 'plan' or 'apply'", f.step)
+	}
+
+	return nil
+}
+
+// Returns whether the given test is selected to run. If it isn't, the reason
+// is returned as well.
+func (f *testFilter) selectTest(test Test) (bool, string) {
+	if len(f.namePatterns) > 0 {
+		matched := false
+		for _, pattern := range f.namePatterns {
+			// Patterns are already validated so the error can be ignored. Names
+			// are also matched exactly, as they may contain glob metacharacters.
+			if ok, _ := path.Match(pattern, test.Name); ok || pattern == test.Name {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false, "name does not match any of the patterns " + f.namePatterns.String()
+		}
+	}
+
+	testTags := map[string]bool{}
+	for _, tag := range test.Tags {
+		testTags[tag] = true
+	}
+
+	for _, tag := range splitTags(f.excludeTags) {
+		if testTags[tag] {
+			return false, fmt.Sprintf("tag '%s' is excluded", tag)
+		}
+	}
+
+	includeTags := splitTags(f.includeTags)
+	if len(includeTags) == 0 {
+		return true, ""
+	}
+
+	for _, tag := range includeTags {
+		if testTags[tag] {
+			return true, ""
+		}
+	}
+
+	return false, "none of the tags " + strings.Join(includeTags, ",") + " are set"
+}
+
+func (f *testFilter) runPlan() bool {
+	return f.step != "apply"
+}
+
+func (f *testFilter) runApply() bool {
+	return f.step != "plan"
+}
+
+// Tags can be passed either by repeating the flag or as a comma separated list.
+func splitTags(tagFlags []string) []string {
+	tags := []string{}
+	for _, tagFlag := range tagFlags {
+		for _, tag := range strings.Split(tagFlag, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	return tags
+}
@@ -0,0 +1,135 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestFilterSelectTest(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   testFilter
+		test     Test
+		selected bool
+		reason   string
+	}{
+		{
+			name:     "all tests are selected without a filter",
+			test:     Test{Name: "Bucket"},
+			selected: true,
+		},
+		{
+			name:     "names are matched as globs",
+			filter:   testFilter{namePatterns: stringSliceFlag{"Buck*"}},
+			test:     Test{Name: "Bucket"},
+			selected: true,
+		},
+		{
+			name:     "names matching none of the patterns are not selected",
+			filter:   testFilter{namePatterns: stringSliceFlag{"Role*", "Policy"}},
+			test:     Test{Name: "Bucket"},
+			selected: false,
+			reason:   "name does not match any of the patterns Role*,Policy",
+		},
+		{
+			name:     "names with glob metacharacters are matched exactly",
+			filter:   testFilter{namePatterns: stringSliceFlag{"Bucket[region=us]"}},
+			test:     Test{Name: "Bucket[region=us]"},
+			selected: true,
+		},
+		{
+			name:     "matrix test names are matched",
+			filter:   testFilter{namePatterns: stringSliceFlag{"Bucket(*)"}},
+			test:     Test{Name: "Bucket(region=us)"},
+			selected: true,
+		},
+		{
+			name:     "tests with an included tag are selected",
+			filter:   testFilter{includeTags: stringSliceFlag{"slow", "fast"}},
+			test:     Test{Name: "Bucket", Tags: []string{"fast"}},
+			selected: true,
+		},
+		{
+			name:     "included tags can be comma separated",
+			filter:   testFilter{includeTags: stringSliceFlag{"slow, fast"}},
+			test:     Test{Name: "Bucket", Tags: []string{"fast"}},
+			selected: true,
+		},
+		{
+			name:     "tests without any included tag are not selected",
+			filter:   testFilter{includeTags: stringSliceFlag{"slow,network"}},
+			test:     Test{Name: "Bucket", Tags: []string{"fast"}},
+			selected: false,
+			reason:   "none of the tags slow,network are set",
+		},
+		{
+			name:     "tests with an excluded tag are not selected",
+			filter:   testFilter{excludeTags: stringSliceFlag{"slow"}},
+			test:     Test{Name: "Bucket", Tags: []string{"fast", "slow"}},
+			selected: false,
+			reason:   "tag 'slow' is excluded",
+		},
+		{
+			name:     "excluded tags take precedence over included tags",
+			filter:   testFilter{includeTags: stringSliceFlag{"fast"}, excludeTags: stringSliceFlag{"slow"}},
+			test:     Test{Name: "Bucket", Tags: []string{"fast", "slow"}},
+			selected: false,
+			reason:   "tag 'slow' is excluded",
+		},
+		{
+			name:     "names and tags must both match",
+			filter:   testFilter{namePatterns: stringSliceFlag{"Role"}, includeTags: stringSliceFlag{"fast"}},
+			test:     Test{Name: "Bucket", Tags: []string{"fast"}},
+			selected: false,
+			reason:   "name does not match any of the patterns Role",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selected, reason := test.filter.selectTest(test.test)
+
+			assert.Equal(t, test.selected, selected)
+			assert.Equal(t, test.reason, reason)
+		})
+	}
+}
+
+func TestTestFilterValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter testFilter
+		err    string
+	}{
+		{
+			name:   "steps can be plan or apply",
+			filter: testFilter{step: "apply", namePatterns: stringSliceFlag{"Bucket*"}},
+		},
+		{
+			name:   "invalid name patterns are rejected",
+			filter: testFilter{namePatterns: stringSliceFlag{"Bucket["}},
+			err:    "invalid test name pattern 'Bucket['",
+		},
+		{
+			name:   "unknown steps are rejected",
+			filter: testFilter{step: "destroy"},
+			err:    "step 'destroy' is invalid",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.filter.validate()
+			if test.err == "" {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), test.err)
+			}
+		})
+	}
+}
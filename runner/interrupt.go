@@ -0,0 +1,157 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const DEFAULT_INTERRUPT_GRACE_PERIOD = 10 * time.Minute
+
+// Exit code used when the run is stopped by a second signal or once the grace
+// period expires.
+const INTERRUPTED_EXIT_CODE = 130
+
+// interruption records the first SIGINT or SIGTERM received while the tests
+// run. Functions registered with onInterrupt are called once it is received,
+// e.g. to stop the remaining tests, and its context is cancelled so that the
+// running steps stop.
+type interruption struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	mu       sync.Mutex
+	signal   os.Signal
+	handlers []func()
+}
+
+var interrupt = newInterruption()
+
+func newInterruption() *interruption {
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	return &interruption{ctx: ctx, cancel: cancel}
+}
+
+// Returns whether the run was interrupted, and the reason.
+func (i *interruption) interrupted() (bool, string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.signal == nil {
+		return false, ""
+	}
+
+	return true, interruptionReason(i.signal)
+}
+
+// Returns a context which is cancelled once the run is interrupted, with the
+// reason as its cause.
+func (i *interruption) context() context.Context {
+	return i.ctx
+}
+
+// Registers a function to call once the run is interrupted. It is called right
+// away if the run is already interrupted.
+func (i *interruption) onInterrupt(f func()) {
+	i.mu.Lock()
+	if i.signal == nil {
+		i.handlers = append(i.handlers, f)
+		i.mu.Unlock()
+
+		return
+	}
+	i.mu.Unlock()
+
+	f()
+}
+
+func (i *interruption) trigger(sig os.Signal) {
+	i.mu.Lock()
+	i.signal = sig
+	handlers := i.handlers
+	i.handlers = nil
+	i.mu.Unlock()
+
+	// The commands of the running steps are interrupted through their context.
+	// They run in their own process group, so they don't receive the SIGINT a
+	// terminal sends to infra-tester as well.
+	i.cancel(errors.New(interruptionReason(sig)))
+
+	for _, handler := range handlers {
+		handler()
+	}
+}
+
+func interruptionReason(sig os.Signal) string {
+	return fmt.Sprintf("the run was interrupted by %s", signalName(sig))
+}
+
+// Handles SIGINT and SIGTERM while the tests run. On the first signal, the
+// running steps are interrupted and the remaining tests are skipped, while
+// plugin cleanups and destroys are allowed to finish within the grace period.
+// A second signal, or the end of the grace period, calls forceExit. The
+// returned function stops handling the signals, and must be called before the
+// test used by forceExit returns.
+func handleInterrupts(gracePeriod time.Duration, forceExit func()) func() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	var mu sync.Mutex
+	stopped := false
+	done := make(chan struct{})
+
+	go func() {
+		var sig os.Signal
+		select {
+		case sig = <-signals:
+		case <-done:
+			return
+		}
+
+		log.Printf("WARNING: Received %s, skipping the remaining tests and cleaning up. "+
+			"This may take up to %s, send the signal again to exit right away", signalName(sig), gracePeriod)
+
+		interrupt.trigger(sig)
+
+		select {
+		case sig = <-signals:
+			log.Printf("ERROR: Received %s again, exiting without cleaning up", signalName(sig))
+		case <-time.After(gracePeriod):
+			log.Printf("ERROR: The cleanup did not finish within the grace period of %s, exiting", gracePeriod)
+		case <-done:
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		// The test may have returned in the meantime.
+		if !stopped {
+			forceExit()
+		}
+	}()
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		stopped = true
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+func signalName(sig os.Signal) string {
+	if sig == syscall.SIGTERM {
+		return "SIGTERM"
+	}
+
+	return "SIGINT"
+}
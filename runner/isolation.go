@@ -0,0 +1,214 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const BACKEND_OVERRIDE_FILE = "infra_tester_backend_override.tf"
+
+// Override file forcing a local backend, so that the state of an isolated
+// test never interferes with the state of other tests.
+const BACKEND_OVERRIDE = `terraform {
+  backend "local" {
+    path = "terraform.tfstate"
+  }
+}
+`
+
+// Copies the Terraform module in the given directory to a temporary directory
+// which is removed at the end of the test, and returns the directory of the
+// module in the copy. Modules referenced with local paths such as
+// ../modules/x are copied as well, keeping their location relative to the
+// module, so that they still resolve in the copy. Nothing else is copied, and
+// neither are existing state, .terraform and .git directories. With
+// localBackend a local backend is configured for the module in the copy,
+// otherwise the copy uses the backend of the module.
+func copyModuleToTemp(t *testing.T, moduleDir string, localBackend bool) (string, error) {
+	if moduleDir == "" {
+		moduleDir = "."
+	}
+
+	sourceDir, err := filepath.Abs(moduleDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve module directory %s: %s", moduleDir, err)
+	}
+
+	moduleDirs, err := findLocalModules(sourceDir)
+	if err != nil {
+		return "", err
+	}
+
+	rootDir := commonParentDir(moduleDirs)
+	moduleSubDir, err := filepath.Rel(rootDir, sourceDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve module directory %s in %s: %s", sourceDir, rootDir, err)
+	}
+
+	destDir := t.TempDir()
+
+	if err := files.CopyFolderContentsWithFilter(rootDir, destDir, moduleCopyFilter(rootDir, moduleDirs)); err != nil {
+		return "", fmt.Errorf("failed to copy %s to %s: %s", rootDir, destDir, err)
+	}
+
+	destModuleDir := filepath.Join(destDir, moduleSubDir)
+	if !localBackend {
+		return destModuleDir, nil
+	}
+
+	if err := os.WriteFile(filepath.Join(destModuleDir, BACKEND_OVERRIDE_FILE), []byte(BACKEND_OVERRIDE), 0644); err != nil {
+		return "", fmt.Errorf("failed to configure local backend: %s", err)
+	}
+
+	return destModuleDir, nil
+}
+
+// Returns the given absolute module directory along with the directories of
+// all the modules it references with local paths, directly or through other
+// local modules.
+func findLocalModules(moduleDir string) ([]string, error) {
+	moduleDirs := []string{moduleDir}
+	seenDirs := map[string]bool{moduleDir: true}
+
+	for i := 0; i < len(moduleDirs); i++ {
+		sources, err := localModuleSources(moduleDirs[i])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, source := range sources {
+			dir := filepath.Join(moduleDirs[i], filepath.FromSlash(source))
+			if seenDirs[dir] {
+				continue
+			}
+
+			seenDirs[dir] = true
+			moduleDirs = append(moduleDirs, dir)
+		}
+	}
+
+	return moduleDirs, nil
+}
+
+var moduleSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "module", LabelNames: []string{"name"}}},
+}
+
+var moduleSourceSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "source"}},
+}
+
+// Returns the local paths, i.e. starting with ./ or ../, used as the source of
+// the module blocks of the Terraform files in the given directory.
+func localModuleSources(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module directory %s: %s", dir, err)
+	}
+
+	parser := hclparse.NewParser()
+	sources := []string{}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		var file *hcl.File
+		var diags hcl.Diagnostics
+		switch {
+		case entry.IsDir():
+			continue
+		case strings.HasSuffix(entry.Name(), ".tf"):
+			file, diags = parser.ParseHCLFile(path)
+		case strings.HasSuffix(entry.Name(), ".tf.json"):
+			file, diags = parser.ParseJSONFile(path)
+		default:
+			continue
+		}
+
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("failed to parse %s: %s", path, diags)
+		}
+
+		content, _, _ := file.Body.PartialContent(moduleSchema)
+		for _, block := range content.Blocks {
+			moduleContent, _, _ := block.Body.PartialContent(moduleSourceSchema)
+			attribute, ok := moduleContent.Attributes["source"]
+			if !ok {
+				continue
+			}
+
+			// Sources which are not literal strings are left to Terraform.
+			value, diags := attribute.Expr.Value(nil)
+			if diags.HasErrors() || value.Type() != cty.String || value.IsNull() {
+				continue
+			}
+
+			source := value.AsString()
+			if strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../") {
+				sources = append(sources, source)
+			}
+		}
+	}
+
+	return sources, nil
+}
+
+// Returns the deepest directory containing all the given absolute directories.
+func commonParentDir(dirs []string) string {
+	parent := dirs[0]
+	for _, dir := range dirs[1:] {
+		for !isInDir(dir, parent) {
+			next := filepath.Dir(parent)
+			if next == parent {
+				break
+			}
+
+			parent = next
+		}
+	}
+
+	return parent
+}
+
+// Returns whether the path is the given directory or inside of it.
+func isInDir(path string, dir string) bool {
+	relPath, err := filepath.Rel(dir, path)
+
+	return err == nil && relPath != ".." && !strings.HasPrefix(relPath, ".."+string(filepath.Separator))
+}
+
+// Returns the filter for copying the module directories from the root
+// directory. Paths inside of the module directories are copied, as well as the
+// directories leading to them, but not the other files next to them.
+func moduleCopyFilter(rootDir string, moduleDirs []string) func(path string) bool {
+	return func(path string) bool {
+		// Only look at paths relative to the root so that hidden directories
+		// in the path of the root itself don't matter.
+		relPath, err := filepath.Rel(rootDir, path)
+		if err != nil {
+			return false
+		}
+
+		for _, part := range strings.Split(relPath, string(filepath.Separator)) {
+			if part == ".terraform" || part == ".git" || strings.HasPrefix(part, "terraform.tfstate") || strings.HasSuffix(part, ".tfstate") {
+				return false
+			}
+		}
+
+		for _, moduleDir := range moduleDirs {
+			if isInDir(path, moduleDir) || isInDir(moduleDir, path) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
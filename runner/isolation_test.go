@@ -0,0 +1,157 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindLocalModules(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected []string
+		err      string
+	}{
+		{
+			name:     "modules without local sources",
+			files:    map[string]string{"app/main.tf": `module "vpc" { source = "terraform-aws-modules/vpc/aws" }`},
+			expected: []string{"app"},
+		},
+		{
+			name: "local sources are found in .tf and .tf.json files",
+			files: map[string]string{
+				"app/main.tf":          `module "network" { source = "../modules/network" }`,
+				"app/storage.tf.json":  `{"module": {"storage": {"source": "./storage"}}}`,
+				"app/storage/main.tf":  ``,
+				"modules/network/x.tf": ``,
+			},
+			expected: []string{"app", "modules/network", "app/storage"},
+		},
+		{
+			name: "local sources of local modules are followed",
+			files: map[string]string{
+				"app/main.tf":             `module "network" { source = "../modules/network" }`,
+				"modules/network/main.tf": `module "subnet" { source = "../subnet" }`,
+				"modules/subnet/main.tf":  ``,
+			},
+			expected: []string{"app", "modules/network", "modules/subnet"},
+		},
+		{
+			name: "modules referenced multiple times are only found once",
+			files: map[string]string{
+				"app/main.tf":             "module \"a\" { source = \"../modules/network\" }\nmodule \"b\" { source = \"../modules/network/\" }",
+				"modules/network/main.tf": `module "app" { source = "../../app" }`,
+			},
+			expected: []string{"app", "modules/network"},
+		},
+		{
+			name: "sources which are not local paths or literals are ignored",
+			files: map[string]string{
+				"app/main.tf": "module \"a\" { source = \"git::https://example.com/network.git\" }\n" +
+					"module \"b\" { source = var.source }\n" +
+					"module \"c\" { source = \"modules/network\" }\n" +
+					"module \"d\" {}",
+			},
+			expected: []string{"app"},
+		},
+		{
+			name:  "invalid Terraform files are an error",
+			files: map[string]string{"app/main.tf": `module "network" {`},
+			err:   "failed to parse",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range test.files {
+				path := filepath.Join(root, filepath.FromSlash(name))
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+				require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+			}
+
+			moduleDirs, err := findLocalModules(filepath.Join(root, "app"))
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			expected := []string{}
+			for _, dir := range test.expected {
+				expected = append(expected, filepath.Join(root, filepath.FromSlash(dir)))
+			}
+
+			assert.ElementsMatch(t, expected, moduleDirs)
+		})
+	}
+}
+
+func TestCommonParentDir(t *testing.T) {
+	tests := []struct {
+		name     string
+		dirs     []string
+		expected string
+	}{
+		{"a single directory", []string{"/repo/app"}, "/repo/app"},
+		{"directories inside of the first one", []string{"/repo/app", "/repo/app/storage"}, "/repo/app"},
+		{"sibling directories", []string{"/repo/app", "/repo/modules/network"}, "/repo"},
+		{"directories with a common prefix", []string{"/repo/app", "/repo/app2"}, "/repo"},
+		{"directories without a common parent", []string{"/repo/app", "/other"}, "/"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dirs := []string{}
+			for _, dir := range test.dirs {
+				dirs = append(dirs, filepath.FromSlash(dir))
+			}
+
+			assert.Equal(t, filepath.FromSlash(test.expected), commonParentDir(dirs))
+		})
+	}
+}
+
+func TestModuleCopyFilter(t *testing.T) {
+	root := filepath.FromSlash("/home/.ci/repo")
+	filter := moduleCopyFilter(root, []string{
+		filepath.Join(root, "app"),
+		filepath.Join(root, "modules", "network"),
+	})
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"app", true},
+		{"app/main.tf", true},
+		{"app/files/policy.json", true},
+		{"modules", true},
+		{"modules/network/main.tf", true},
+		// Files next to the modules are not copied.
+		{"README.md", false},
+		{"modules/other/main.tf", false},
+		{"app2/main.tf", false},
+		// Neither is state nor the data of Terraform and git.
+		{"app/.terraform/modules/modules.json", false},
+		{"app/terraform.tfstate", false},
+		{"app/terraform.tfstate.backup", false},
+		{"app/terraform.tfstate.d/dev/terraform.tfstate", false},
+		{"app/prod.tfstate", false},
+		{".git", false},
+		{"app/.git/HEAD", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			assert.Equal(t, test.expected, filter(filepath.Join(root, filepath.FromSlash(test.path))))
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package runner
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Expands a test into one test per combination of var values, similar to the
+// matrix of CI systems.
+type Matrix struct {
+	// Lists of values for each var, keyed by var name.
+	Values map[string]interface{} `mapstructure:",remain"`
+	// Combinations to add, or to extend with extra vars if they match an
+	// existing combination.
+	Include []map[string]interface{}
+	// Partial combinations to remove.
+	Exclude []map[string]interface{}
+}
+
+// Replaces the tests defining a matrix with one test per combination. Other
+// tests are kept as they are, and the order of the tests is preserved.
+func expandMatrixTests(tests []Test) ([]Test, error) {
+	expandedTests := make([]Test, 0, len(tests))
+
+	for _, test := range tests {
+		if test.Matrix == nil {
+			expandedTests = append(expandedTests, test)
+
+			continue
+		}
+
+		combinations, err := test.Matrix.combinations()
+		if err != nil {
+			return nil, fmt.Errorf("invalid matrix for test '%s': %s", test.Name, err)
+		}
+
+		for _, combination := range combinations {
+			expandedTest := test
+			expandedTest.Name = matrixTestName(test.Name, combination)
+			expandedTest.Matrix = nil
+			expandedTest.Vars = deepCopyVars(test.Vars)
+
+			for key, value := range combination {
+				expandedTest.Vars[key] = deepCopyValue(value)
+			}
+
+			expandedTests = append(expandedTests, expandedTest)
+		}
+	}
+
+	return expandedTests, nil
+}
+
+// Returns all the combinations of the matrix. Vars are combined in the
+// alphabetical order of their names, and the values in the order they are
+// listed, so that the combinations are always the same.
+func (matrix Matrix) combinations() ([]map[string]interface{}, error) {
+	keys := make([]string, 0, len(matrix.Values))
+	for key := range matrix.Values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	combinations := []map[string]interface{}{}
+	if len(keys) > 0 {
+		combinations = append(combinations, map[string]interface{}{})
+	}
+
+	for _, key := range keys {
+		values, ok := matrix.Values[key].([]interface{})
+		if !ok || len(values) == 0 {
+			return nil, fmt.Errorf("values of '%s' must be a non empty list", key)
+		}
+
+		expanded := make([]map[string]interface{}, 0, len(combinations)*len(values))
+		for _, combination := range combinations {
+			for _, value := range values {
+				newCombination := make(map[string]interface{}, len(combination)+1)
+				for k, v := range combination {
+					newCombination[k] = v
+				}
+
+				newCombination[key] = value
+				expanded = append(expanded, newCombination)
+			}
+		}
+
+		combinations = expanded
+	}
+
+	for _, exclude := range matrix.Exclude {
+		if len(exclude) == 0 {
+			return nil, fmt.Errorf("exclude entries must not be empty")
+		}
+
+		for key := range exclude {
+			if _, ok := matrix.Values[key]; !ok {
+				return nil, fmt.Errorf("exclude entry refers to '%s' which is not a matrix var", key)
+			}
+		}
+
+		kept := combinations[:0]
+		for _, combination := range combinations {
+			if !matchesCombination(combination, exclude) {
+				kept = append(kept, combination)
+			}
+		}
+
+		combinations = kept
+	}
+
+	// Include entries only extend the combinations of the matrix, not the
+	// combinations added by other include entries.
+	matrixCombinations := len(combinations)
+	for _, include := range matrix.Include {
+		if len(include) == 0 {
+			return nil, fmt.Errorf("include entries must not be empty")
+		}
+
+		var err error
+		combinations, err = includeCombination(combinations, matrixCombinations, include, matrix.Values)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(combinations) == 0 {
+		return nil, fmt.Errorf("matrix has no combinations")
+	}
+
+	return combinations, nil
+}
+
+// Adds the include entry to the combinations. Like in CI matrices, the entry
+// extends all the combinations of the matrix whose values it doesn't change
+// with its vars, extra vars set by other include entries can be changed. An
+// entry which can't extend any combination is added as a new combination, it
+// must then define all the matrix vars.
+func includeCombination(combinations []map[string]interface{}, matrixCombinations int, include map[string]interface{}, values map[string]interface{}) ([]map[string]interface{}, error) {
+	matrixVars := map[string]interface{}{}
+	for key, value := range include {
+		if _, ok := values[key]; ok {
+			matrixVars[key] = value
+		}
+	}
+
+	extended := false
+	for _, combination := range combinations[:matrixCombinations] {
+		if !matchesCombination(combination, matrixVars) {
+			continue
+		}
+
+		for key, value := range include {
+			combination[key] = value
+		}
+
+		extended = true
+	}
+
+	if extended {
+		return combinations, nil
+	}
+
+	missingVars := []string{}
+	for key := range values {
+		if _, ok := include[key]; !ok {
+			missingVars = append(missingVars, key)
+		}
+	}
+
+	if len(missingVars) > 0 {
+		sort.Strings(missingVars)
+
+		return nil, fmt.Errorf("include entry %v doesn't match any combination, and is missing the matrix vars %s to be added as a new combination",
+			include, strings.Join(missingVars, ", "))
+	}
+
+	for _, combination := range combinations {
+		if reflect.DeepEqual(combination, include) {
+			return combinations, nil
+		}
+	}
+
+	newCombination := make(map[string]interface{}, len(include))
+	for key, value := range include {
+		newCombination[key] = value
+	}
+
+	return append(combinations, newCombination), nil
+}
+
+// Returns whether the combination has the same values for all the vars of the
+// partial combination.
+func matchesCombination(combination map[string]interface{}, partial map[string]interface{}) bool {
+	for key, value := range partial {
+		combinationValue, ok := combination[key]
+		if !ok || !reflect.DeepEqual(combinationValue, value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the name of the test generated for the combination, e.g.
+// "Bucket(region=us-east-1,size=small)". Parentheses are used as they have no
+// meaning in the glob patterns of --name.
+func matrixTestName(testName string, combination map[string]interface{}) string {
+	keys := make([]string, 0, len(combination))
+	for key := range combination {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%v", key, combination[key]))
+	}
+
+	return fmt.Sprintf("%s(%s)", testName, strings.Join(parts, ","))
+}
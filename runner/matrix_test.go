@@ -0,0 +1,235 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatrixCombinations(t *testing.T) {
+	tests := []struct {
+		name     string
+		matrix   Matrix
+		expected []map[string]interface{}
+		err      string
+	}{
+		{
+			name: "values are combined in the alphabetical order of the vars",
+			matrix: Matrix{Values: map[string]interface{}{
+				"size":   []interface{}{"small", "large"},
+				"region": []interface{}{"us", "eu"},
+			}},
+			expected: []map[string]interface{}{
+				{"region": "us", "size": "small"},
+				{"region": "us", "size": "large"},
+				{"region": "eu", "size": "small"},
+				{"region": "eu", "size": "large"},
+			},
+		},
+		{
+			name: "exclude removes the combinations matching all its vars",
+			matrix: Matrix{
+				Values: map[string]interface{}{
+					"region": []interface{}{"us", "eu"},
+					"size":   []interface{}{"small", "large"},
+				},
+				Exclude: []map[string]interface{}{{"region": "eu", "size": "large"}},
+			},
+			expected: []map[string]interface{}{
+				{"region": "us", "size": "small"},
+				{"region": "us", "size": "large"},
+				{"region": "eu", "size": "small"},
+			},
+		},
+		{
+			name: "exclude with a partial combination removes all the matching combinations",
+			matrix: Matrix{
+				Values: map[string]interface{}{
+					"region": []interface{}{"us", "eu"},
+					"size":   []interface{}{"small", "large"},
+				},
+				Exclude: []map[string]interface{}{{"region": "eu"}},
+			},
+			expected: []map[string]interface{}{
+				{"region": "us", "size": "small"},
+				{"region": "us", "size": "large"},
+			},
+		},
+		{
+			name: "include with extra vars extends the matching combinations",
+			matrix: Matrix{
+				Values: map[string]interface{}{
+					"region": []interface{}{"us", "eu"},
+					"size":   []interface{}{"small", "large"},
+				},
+				Include: []map[string]interface{}{{"region": "us", "versioning": true}},
+			},
+			expected: []map[string]interface{}{
+				{"region": "us", "size": "small", "versioning": true},
+				{"region": "us", "size": "large", "versioning": true},
+				{"region": "eu", "size": "small"},
+				{"region": "eu", "size": "large"},
+			},
+		},
+		{
+			name: "include with only extra vars extends all the combinations",
+			matrix: Matrix{
+				Values:  map[string]interface{}{"region": []interface{}{"us", "eu"}},
+				Include: []map[string]interface{}{{"versioning": true}},
+			},
+			expected: []map[string]interface{}{
+				{"region": "us", "versioning": true},
+				{"region": "eu", "versioning": true},
+			},
+		},
+		{
+			name: "include with only matrix vars matching partially adds nothing",
+			matrix: Matrix{
+				Values: map[string]interface{}{
+					"region": []interface{}{"us", "eu"},
+					"size":   []interface{}{"small", "large"},
+				},
+				Include: []map[string]interface{}{{"region": "us"}},
+			},
+			expected: []map[string]interface{}{
+				{"region": "us", "size": "small"},
+				{"region": "us", "size": "large"},
+				{"region": "eu", "size": "small"},
+				{"region": "eu", "size": "large"},
+			},
+		},
+		{
+			name: "include matching no combination is added as a new combination",
+			matrix: Matrix{
+				Values: map[string]interface{}{
+					"region": []interface{}{"us"},
+					"size":   []interface{}{"small"},
+				},
+				Include: []map[string]interface{}{{"region": "ap", "size": "small", "versioning": true}},
+			},
+			expected: []map[string]interface{}{
+				{"region": "us", "size": "small"},
+				{"region": "ap", "size": "small", "versioning": true},
+			},
+		},
+		{
+			name: "include adds back an excluded combination",
+			matrix: Matrix{
+				Values: map[string]interface{}{
+					"region": []interface{}{"us", "eu"},
+					"size":   []interface{}{"small"},
+				},
+				Exclude: []map[string]interface{}{{"region": "eu"}},
+				Include: []map[string]interface{}{{"region": "eu", "size": "small"}},
+			},
+			expected: []map[string]interface{}{
+				{"region": "us", "size": "small"},
+				{"region": "eu", "size": "small"},
+			},
+		},
+		{
+			name: "include can change extra vars set by other include entries",
+			matrix: Matrix{
+				Values: map[string]interface{}{"region": []interface{}{"us", "eu"}},
+				Include: []map[string]interface{}{
+					{"versioning": false},
+					{"region": "us", "versioning": true},
+				},
+			},
+			expected: []map[string]interface{}{
+				{"region": "us", "versioning": true},
+				{"region": "eu", "versioning": false},
+			},
+		},
+		{
+			name: "include doesn't extend combinations added by other include entries",
+			matrix: Matrix{
+				Values: map[string]interface{}{"region": []interface{}{"us"}},
+				Include: []map[string]interface{}{
+					{"region": "eu"},
+					{"region": "eu", "versioning": true},
+				},
+			},
+			expected: []map[string]interface{}{
+				{"region": "us"},
+				{"region": "eu"},
+				{"region": "eu", "versioning": true},
+			},
+		},
+		{
+			name: "include which would add an incomplete combination is rejected",
+			matrix: Matrix{
+				Values: map[string]interface{}{
+					"region": []interface{}{"us"},
+					"size":   []interface{}{"small"},
+				},
+				Include: []map[string]interface{}{{"region": "eu", "versioning": true}},
+			},
+			err: "missing the matrix vars size",
+		},
+		{
+			name:   "values must be a list",
+			matrix: Matrix{Values: map[string]interface{}{"region": "us"}},
+			err:    "values of 'region' must be a non empty list",
+		},
+		{
+			name: "exclude must refer to matrix vars",
+			matrix: Matrix{
+				Values:  map[string]interface{}{"region": []interface{}{"us"}},
+				Exclude: []map[string]interface{}{{"size": "small"}},
+			},
+			err: "exclude entry refers to 'size' which is not a matrix var",
+		},
+		{
+			name: "excluding all the combinations is an error",
+			matrix: Matrix{
+				Values:  map[string]interface{}{"region": []interface{}{"us"}},
+				Exclude: []map[string]interface{}{{"region": "us"}},
+			},
+			err: "matrix has no combinations",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			combinations, err := test.matrix.combinations()
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, combinations)
+		})
+	}
+}
+
+func TestExpandMatrixTests(t *testing.T) {
+	tests := []Test{
+		{Name: "Plain"},
+		{
+			Name: "Bucket",
+			Vars: map[string]interface{}{"force_destroy": true, "region": "default"},
+			Matrix: &Matrix{Values: map[string]interface{}{
+				"region": []interface{}{"us", "eu"},
+			}},
+		},
+	}
+
+	expandedTests, err := expandMatrixTests(tests)
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, test := range expandedTests {
+		names = append(names, test.Name)
+		assert.Nil(t, test.Matrix)
+	}
+
+	assert.Equal(t, []string{"Plain", "Bucket(region=us)", "Bucket(region=eu)"}, names)
+	assert.Equal(t, map[string]interface{}{"force_destroy": true, "region": "us"}, expandedTests[1].Vars)
+	assert.Equal(t, map[string]interface{}{"force_destroy": true, "region": "eu"}, expandedTests[2].Vars)
+	assert.Equal(t, "default", tests[1].Vars["region"], "the vars of the original test must not be modified")
+}
@@ -0,0 +1,688 @@
+package runner
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/schrodinger/infra-tester/assertions"
+	"github.com/schrodinger/infra-tester/plugins"
+	"github.com/schrodinger/infra-tester/report"
+	"github.com/schrodinger/infra-tester/utils/cmd"
+)
+
+var (
+	configPatterns stringSliceFlag
+	terraformDir   string
+	filter         testFilter
+	junitReport    string
+	jsonReport     string
+	// Override the test plan level terraform_binary and terraform_version.
+	terraformBinary  string
+	terraformVersion string
+	pluginDirs       stringSliceFlag
+	// Time after which plugin commands are stopped, no limit if 0.
+	pluginTimeout time.Duration
+	// How Python plugins get their inputs, state and context.
+	pluginTransport string
+	// Time allowed for cleaning up once the run is interrupted.
+	interruptGracePeriod time.Duration
+)
+
+// Main parses the command line flags and runs the tests of the config files.
+// It is the entrypoint of the infra-tester binary, and can be called from the
+// main function of another binary which registers its own assertions with
+// assertions.Register first.
+func Main() {
+	flag.Var(&configPatterns, "config", "Path or glob pattern of the config files to run, can be specified multiple times "+
+		"(default \"<chdir>/"+DEFAULT_CONFIG_FILE+"\")")
+	flag.StringVar(&terraformDir, "chdir", "", "Directory containing the Terraform code to test (default is the current directory)")
+	flag.Var(&filter.namePatterns, "name", "Only run tests with a name matching the glob pattern, can be specified multiple times")
+	flag.Var(&filter.includeTags, "tags", "Only run tests with at least one of the comma separated tags, can be specified multiple times")
+	flag.Var(&filter.excludeTags, "exclude-tags", "Skip tests with any of the comma separated tags, can be specified multiple times")
+	flag.StringVar(&junitReport, "junit-report", "", "Path to write a JUnit XML report of the test results to")
+	flag.StringVar(&jsonReport, "json-report", "", "Path to write a JSON report of the test results to")
+	flag.StringVar(&terraformBinary, "terraform-binary", "", "Binary used to run Terraform commands, e.g. \"tofu\", overrides terraform_binary of the test plan")
+	flag.StringVar(&terraformVersion, "terraform-version", "", "Version constraint for the Terraform binary, e.g. \">= 1.5\", overrides terraform_version of the test plan")
+	flag.Var(&pluginDirs, "plugin-dir", "Directory to look up executable plugins in before PATH, can be specified multiple times")
+	flag.DurationVar(&pluginTimeout, "plugin-timeout", 0, "Time after which plugin commands are interrupted, e.g. \"5m\" (default no timeout)")
+	flag.StringVar(&pluginTransport, "plugin-transport", plugins.PLUGIN_TRANSPORT_AUTO,
+		"How Python plugins get their inputs and the state, one of \"auto\", \"argv\", \"stdin\" or \"file\"")
+	flag.DurationVar(&interruptGracePeriod, "interrupt-grace-period", DEFAULT_INTERRUPT_GRACE_PERIOD,
+		"Time allowed for plugin cleanups and destroys after SIGINT or SIGTERM before exiting")
+	flag.StringVar(&filter.step, "step", "", "Only run the assertions of the given step, either \"plan\" or \"apply\"")
+
+	// Register the -test.* flags before parsing so that they keep working
+	// alongside infra-tester's own flags.
+	testing.Init()
+	flag.Parse()
+
+	if len(configPatterns) == 0 {
+		configPatterns = stringSliceFlag{filepath.Join(terraformDir, DEFAULT_CONFIG_FILE)}
+	}
+
+	testing.Main(
+		nil,
+		[]testing.InternalTest{
+			{
+				Name: "Tests",
+				F:    Tests,
+			},
+		},
+		nil, nil,
+	)
+}
+
+func Tests(t *testing.T) {
+	// The results of the test plans are recorded once the configs are read.
+	run := report.NewRun()
+	rt := run.Setup.T(t)
+	defer writeReports(t, run)
+	defer finishRun(t, run)
+
+	// The reports are still written if the cleanup after an interrupt takes
+	// too long. Signals are no longer handled once the reports are written,
+	// as t must not be used after Tests returned.
+	stopInterrupts := handleInterrupts(interruptGracePeriod, func() {
+		finishRun(t, run)
+		writeReports(t, run)
+		os.Exit(INTERRUPTED_EXIT_CODE)
+	})
+	defer stopInterrupts()
+
+	if err := filter.validate(); err != nil {
+		rt.Fatalf("ERROR: Invalid test filter: %s", err)
+	}
+
+	testPlans, err := getTests(configPatterns)
+	if err != nil {
+		rt.Fatalf("ERROR: Failed to process all tests: %s", err)
+	}
+
+	for i := range testPlans {
+		if terraformBinary != "" {
+			testPlans[i].TerraformBinary = terraformBinary
+		}
+
+		if terraformVersion != "" {
+			testPlans[i].TerraformVersion = terraformVersion
+		}
+	}
+
+	// Build assertion context.
+	assertionContext := buildAssertionContext(rt)
+
+	// Validate the tests of all the test plans before running any of them.
+	for _, testPlan := range testPlans {
+		if err = validateTests(testPlan, assertionContext); err != nil {
+			assertions.ErrorAndSkipf(rt, "ERROR: Failure during test validation of '%s': %s", testPlan.configPath, err)
+		}
+	}
+
+	// Run the test plans one after the other.
+	for _, testPlan := range testPlans {
+		testPlanResult := run.StartTestPlan(testPlan.Name)
+
+		t.Run(testPlan.Name, func(t *testing.T) {
+			defer testPlanResult.Finish(t)
+
+			if interrupted, reason := interrupt.interrupted(); interrupted {
+				t.Skipf("INFO: Skipping the test plan as %s", reason)
+			}
+
+			runTestPlan(t, testPlan, assertionContext, testPlanResult)
+		})
+	}
+}
+
+// Initializes the Terraform module of the test plan and runs its tests. The
+// module is the --chdir directory, unless terraform_dir is set.
+func runTestPlan(
+	t *testing.T,
+	testPlan TestPlan,
+	assertionContext *assertions.AssertionContext,
+	testPlanResult *report.Result) {
+	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{})
+	(*terraformOptions).NoColor = true
+	(*terraformOptions).TerraformDir = terraformDir
+
+	applyTerraformOptions(terraformOptions, testPlan.TerraformOptions, testPlan.configDir())
+
+	checkTerraformVersion(t, testPlanResult.T(t), terraformOptions, testPlan.TerraformVersion, testPlan.OnVersionMismatch)
+
+	_, err := terraform.InitE(t, terraformOptions)
+	if err != nil {
+		assertions.ErrorAndSkipf(testPlanResult.T(t), "ERROR: Failure during terraform init: %s", err)
+	}
+
+	runTests(t, terraformOptions, testPlan, assertionContext, testPlanResult)
+}
+
+// Records the outcome of the test plans which did not finish yet, e.g. because
+// the run exits after an interrupt. If a signal was received, the test plans
+// are recorded as interrupted.
+func finishRun(t *testing.T, run *report.Run) {
+	interrupted, reason := interrupt.interrupted()
+	if interrupted {
+		t.Errorf("ERROR: Stopped early as %s", reason)
+	}
+
+	run.Setup.FinishIfRunning(t)
+
+	testPlanResults := run.TestPlans()
+	if len(testPlanResults) == 0 {
+		testPlanResults = []*report.Result{run.Setup}
+	}
+
+	for _, testPlanResult := range testPlanResults {
+		testPlanResult.FinishIfRunning(t)
+
+		if interrupted {
+			testPlanResult.AddFailure(fmt.Sprintf("ERROR: Stopped early as %s", reason))
+			testPlanResult.SetOutcome(report.INTERRUPTED)
+		}
+	}
+}
+
+// Writes the reports requested on the command line.
+func writeReports(t *testing.T, run *report.Run) {
+	if junitReport != "" {
+		if err := report.WriteJUnit(run, junitReport); err != nil {
+			t.Errorf("ERROR: Failed to write JUnit report: %s", err)
+		}
+	}
+
+	if jsonReport != "" {
+		if err := report.WriteJSON(run, jsonReport); err != nil {
+			t.Errorf("ERROR: Failed to write JSON report: %s", err)
+		}
+	}
+}
+
+func buildAssertionContext(t assertions.TestingT) *assertions.AssertionContext {
+	// Build assertion context.
+	assertionContext := assertions.AssertionContext{}
+
+	// Setup plugins
+	setupPlugins(t, &assertionContext)
+
+	return &assertionContext
+}
+
+func setupPlugins(t assertions.TestingT, assertionContext *assertions.AssertionContext) {
+	// Executable plugins don't need anything to be installed.
+	cmdRunner := cmd.NewCmdRunnerWithTimeout(pluginTimeout)
+	pluginManagers := []plugins.PluginManager{}
+
+	if err := plugins.ValidatePluginTransport(pluginTransport); err != nil {
+		t.Fatalf("ERROR: %s", err)
+	}
+
+	// Check if Python plugins are supported in the current environment. They
+	// take precedence over executable plugins with the same name.
+	if err := plugins.CanRunPlugins(cmdRunner); err == nil {
+		t.Log("INFO: Plugin framework is installed.")
+		pipPluginManager, err := plugins.NewPipPluginManagerWithTransport(cmdRunner, pluginTransport)
+
+		if err != nil {
+			t.Fatalf("ERROR: Failed to create plugin manager: %s."+
+				"Please file an issue with the logs.", err)
+		}
+
+		pluginManagers = append(pluginManagers, pipPluginManager)
+	} else {
+		t.Logf("INFO: Can not use Python plugins in this environment: %s", err)
+	}
+
+	for _, pluginDir := range pluginDirs {
+		if info, err := os.Stat(pluginDir); err != nil || !info.IsDir() {
+			t.Fatalf("ERROR: Plugin directory '%s' does not exist or is not a directory", pluginDir)
+		}
+	}
+
+	pluginManagers = append(pluginManagers, plugins.NewExecPluginManager(cmdRunner, pluginDirs))
+
+	pluginManager := plugins.NewMultiPluginManager(pluginManagers...)
+
+	var err error
+	assertionContext.PluginManager = &pluginManager
+	assertionContext.AvailablePlugins, err = pluginManager.ListPlugins()
+	if err != nil {
+		t.Fatalf("ERROR: Failed to list plugins: %s. "+
+			"Please raise an issue with the logs", err)
+	}
+}
+
+func runTests(
+	t *testing.T,
+	terraformOptions *terraform.Options,
+	testPlan TestPlan,
+	assertionContext *assertions.AssertionContext,
+	testPlanResult *report.Result) {
+	// The final destroy uses the vars, env_vars and var_files of the last
+	// sequential test that ran, as the test may provide required variables
+	// through any of them. destroy_vars take precedence over the vars.
+	destroyOptions, err := terraformOptions.Clone()
+	if err != nil {
+		t.Fatalf("ERROR: Failed to copy terraform options: %s", err)
+	}
+
+	destroyOptions.Vars = deepCopyVars(testPlan.Vars)
+
+	// Run destroy regardless of test results to clean up any left overs
+	defer deferDestroy(t, destroyOptions, testPlan, testPlanResult)()
+
+	// Set once the remaining tests must not run, e.g. because of fail_fast.
+	stop := &testPlanStop{}
+	interrupt.onInterrupt(func() {
+		_, reason := interrupt.interrupted()
+		stop.stop(reason)
+	})
+
+	// Limits the number of parallel tests running at the same time.
+	var parallelSlots chan struct{}
+	if testPlan.MaxParallel > 0 {
+		parallelSlots = make(chan struct{}, testPlan.MaxParallel)
+	}
+
+	// Parallel tests only start once the function of their parent returns, so
+	// the tests run in a group which only returns once all of them are done,
+	// before the final destroy runs.
+	t.Run("Tests", func(t *testing.T) {
+		for _, test := range testPlan.Tests {
+			test := test
+
+			// Tests that are not selected are still reported, but as skipped.
+			testResult := testPlanResult.Start(report.KIND_TEST, test.Name)
+
+			if selected, reason := filter.selectTest(test); !selected {
+				t.Run(test.Name, func(t *testing.T) {
+					defer testResult.Finish(t)
+
+					t.Skipf("INFO: Skipping %s as it is not selected: %s", test.Name, reason)
+				})
+
+				continue
+			}
+
+			if stopped, reason := stop.stopped(); stopped {
+				t.Run(test.Name, func(t *testing.T) {
+					defer testResult.Finish(t)
+
+					t.Skipf("INFO: Skipping %s as %s", test.Name, reason)
+				})
+
+				continue
+			}
+
+			if test.isParallel(testPlan) {
+				// Parallel tests only start once all the sequential tests are
+				// done, and run in their own working directory and state.
+				t.Run(test.Name, func(t *testing.T) {
+					defer testResult.Finish(t)
+
+					t.Parallel()
+
+					if parallelSlots != nil {
+						parallelSlots <- struct{}{}
+						defer func() { <-parallelSlots }()
+					}
+
+					// The test plan may have been stopped while waiting.
+					if stopped, reason := stop.stopped(); stopped {
+						t.Skipf("INFO: Skipping %s as %s", test.Name, reason)
+					}
+
+					abortPlan := false
+					defer func() { stop.stopAfterTest(t, testPlan, test, abortPlan) }()
+
+					abortPlan = runIsolatedTest(t, test, terraformOptions, testPlan, assertionContext, testResult, true)
+				})
+
+				continue
+			}
+
+			if test.TerraformOptions.needsOwnWorkingDir() {
+				// Tests using another module can't share the working directory
+				// initialized for the test plan.
+				t.Run(test.Name, func(t *testing.T) {
+					defer testResult.Finish(t)
+
+					abortPlan := false
+					defer func() { stop.stopAfterTest(t, testPlan, test, abortPlan) }()
+
+					abortPlan = runIsolatedTest(t, test, terraformOptions, testPlan, assertionContext, testResult, false)
+				})
+
+				continue
+			}
+
+			t.Run(test.Name, func(t *testing.T) {
+				defer testResult.Finish(t)
+
+				abortPlan := false
+				defer func() { stop.stopAfterTest(t, testPlan, test, abortPlan) }()
+
+				testOptions, err := buildTestOptions(t, terraformOptions, testPlan, test)
+				if err != nil {
+					assertions.ErrorAndSkipf(testResult.T(t), "ERROR: %s", err)
+				}
+
+				if test.TerraformOptions.needsVersionCheck() {
+					checkTerraformVersion(t, testResult.T(t), testOptions, test.terraformVersion(testPlan), testPlan.OnVersionMismatch)
+				}
+
+				// Tests using another binary share the working directory and state
+				// of the test plan, which is initialized with their binary, and
+				// with the binary of the test plan again once they are done.
+				if test.TerraformOptions.needsReinit() {
+					defer func() {
+						if _, err := terraform.InitE(t, terraformOptions); err != nil {
+							testResult.T(t).Errorf("ERROR: Failure during terraform init after %s: %s", test.Name, err)
+						}
+					}()
+
+					if _, err := terraform.InitE(t, testOptions); err != nil {
+						assertions.ErrorAndSkipf(testResult.T(t), "ERROR: Failure during terraform init for %s: %s", test.Name, err)
+					}
+				}
+
+				destroyOptions.Vars = testOptions.Vars
+				destroyOptions.EnvVars = testOptions.EnvVars
+				destroyOptions.VarFiles = testOptions.VarFiles
+
+				abortPlan = runTest(t, test, testPlan, testOptions, assertionContext, testResult)
+			})
+		}
+	})
+
+	t.Log("A final destroy will be called to cleanup any left over resources")
+}
+
+// Runs the plan and apply assertions of the test. Returns whether the plan
+// assertions failed and the remaining tests must be aborted because of
+// on_plan_failure.
+func runTest(
+	t *testing.T,
+	test Test,
+	testPlan TestPlan,
+	terraformOptions *terraform.Options,
+	assertionContext *assertions.AssertionContext,
+	testResult *report.Result) bool {
+	skipPlanTests := !filter.runPlan()
+	skipApplyTests := !filter.runApply()
+	replace := test.replace(testPlan)
+	onPlanFailure := test.onPlanFailure(testPlan)
+	abortPlan := false
+
+	// Run all plan assertions first
+	if test.PlanAssertions.Assertions == nil {
+		t.Logf("No plan assertions for %s", test.Name)
+	} else {
+		runPlanAssertions(t, test, terraformOptions, replace, skipPlanTests, assertionContext, testResult)
+
+		if t.Failed() {
+			switch onPlanFailure {
+			case ON_PLAN_FAILURE_RUN_APPLY:
+				t.Logf("Plan assertions failed for %s, running Apply assertions as on_plan_failure is %s", test.Name, onPlanFailure)
+			case ON_PLAN_FAILURE_ABORT_PLAN:
+				t.Logf("Plan assertions failed for %s, skipping Apply assertions and the remaining tests as on_plan_failure is %s", test.Name, onPlanFailure)
+
+				skipApplyTests = true
+				abortPlan = true
+			default:
+				t.Logf("Plan assertions failed for %s, skipping Apply assertions", test.Name)
+
+				skipApplyTests = true
+			}
+		}
+	}
+
+	// Run all apply assertions
+	if test.ApplyAssertions.Assertions == nil {
+		t.Logf("No apply assertions for %s", test.Name)
+	} else {
+		runApplyAssertions(t, test, terraformOptions, replace, skipApplyTests, assertionContext, testResult)
+	}
+
+	return abortPlan
+}
+
+// Runs the test in a copy of its Terraform module with its own working
+// directory, and destroys the resources created by the test at the end. With
+// localBackend the copy uses its own local state, and the backend config is
+// ignored. Returns whether the remaining tests must be aborted like runTest.
+func runIsolatedTest(
+	t *testing.T,
+	test Test,
+	baseTerraformOptions *terraform.Options,
+	testPlan TestPlan,
+	assertionContext *assertions.AssertionContext,
+	testResult *report.Result,
+	localBackend bool) bool {
+	rt := testResult.T(t)
+
+	terraformOptions, err := buildTestOptions(t, baseTerraformOptions, testPlan, test)
+	if err != nil {
+		assertions.ErrorAndSkipf(rt, "ERROR: %s", err)
+	}
+
+	if test.TerraformOptions.needsVersionCheck() {
+		checkTerraformVersion(t, rt, terraformOptions, test.terraformVersion(testPlan), testPlan.OnVersionMismatch)
+	}
+
+	workingDir, err := copyModuleToTemp(t, terraformOptions.TerraformDir, localBackend)
+	if err != nil {
+		assertions.ErrorAndSkipf(rt, "ERROR: Failed to copy the Terraform module for %s: %s", test.Name, err)
+	}
+
+	t.Logf("INFO: Running %s in isolated working directory %s", test.Name, workingDir)
+
+	// Relative var files are relative to the original module directory, which
+	// is no longer the working directory of Terraform. The slice is shared
+	// with the base options, so a new one is built.
+	varFiles := make([]string, 0, len(terraformOptions.VarFiles))
+	for _, varFile := range terraformOptions.VarFiles {
+		absVarFile, err := filepath.Abs(resolveTerraformDir(terraformOptions.TerraformDir, varFile))
+		if err != nil {
+			assertions.ErrorAndSkipf(rt, "ERROR: Failed to resolve var file %s for %s: %s", varFile, test.Name, err)
+		}
+
+		varFiles = append(varFiles, absVarFile)
+	}
+
+	terraformOptions.VarFiles = varFiles
+	terraformOptions.TerraformDir = workingDir
+	// Only the test plan level backend config can be set for parallel tests,
+	// test level backend configs are rejected by the validation.
+	if localBackend && len(terraformOptions.BackendConfig) > 0 {
+		t.Logf("INFO: Ignoring the test plan backend_config for %s as it uses a local backend", test.Name)
+		terraformOptions.BackendConfig = nil
+	}
+
+	_, err = terraform.InitE(t, terraformOptions)
+	if err != nil {
+		assertions.ErrorAndSkipf(rt, "ERROR: Failure during terraform init: %s", err)
+	}
+
+	// Destroy the resources created by the test.
+	defer deferDestroy(t, terraformOptions, testPlan, testResult)()
+
+	return runTest(t, test, testPlan, terraformOptions, assertionContext, testResult)
+}
+
+func runPlanAssertions(
+	t *testing.T,
+	test Test,
+	terraformOptions *terraform.Options,
+	replace []string,
+	skipTests bool,
+	assertionContext *assertions.AssertionContext,
+	testResult *report.Result) {
+	t.Run("Plan", func(t *testing.T) {
+		stepResult := testResult.Start(report.KIND_STEP, "Plan")
+		defer stepResult.Finish(t)
+
+		if skipTests {
+			t.SkipNow()
+		}
+
+		if interrupted, reason := interrupt.interrupted(); interrupted {
+			t.Skipf("INFO: Skipping the step as %s", reason)
+		}
+
+		ctx, cancel := withTimeout(interrupt.context(), test.PlanAssertions.Timeout, "the plan step")
+		defer cancel()
+
+		if test.WithCleanState {
+			t.Logf("INFO: with_clean_state enabled - running destroy before plan for %s", test.Name)
+			_, err := destroyE(ctx, t, terraformOptions)
+			if err != nil {
+				assertions.ErrorAndSkipf(stepResult.T(t), "ERROR: Failure during terraform destroy: %s", err)
+			}
+		}
+
+		// Work on a copy so that the plan file is not used by later applies.
+		planOptions, err := terraformOptions.Clone()
+		if err != nil {
+			assertions.ErrorAndSkipf(stepResult.T(t), "ERROR: Failed to copy terraform options: %s", err)
+		}
+
+		planOptions.PlanFilePath = filepath.Join(t.TempDir(), "tfplan")
+
+		stdOutErr, plan, err := planAndShow(ctx, t, planOptions, replace)
+		stepResult.SetOutput(stdOutErr)
+
+		// Assertions can't tell a plan which was stopped from a failed one.
+		if ctx.Err() != nil {
+			assertions.ErrorAndSkipf(stepResult.T(t), "ERROR: %s", context.Cause(ctx))
+		}
+
+		planMetadata := assertions.PlanMetadata{CmdOut: stdOutErr, Err: err, Plan: plan, TestName: test.Name}
+
+		for _, assertion := range test.PlanAssertions.Assertions {
+			subTestName := assertion.Type
+			if assertion.Name != "" {
+				subTestName = assertion.Name
+			}
+
+			t.Run(subTestName, func(t *testing.T) {
+				assertionResult := stepResult.StartAssertion(subTestName, assertion.Type)
+				defer assertionResult.Finish(t)
+
+				// Assertions which already ran still run their cleanup.
+				if interrupted, reason := interrupt.interrupted(); interrupted {
+					t.Skipf("INFO: Skipping %s as %s", subTestName, reason)
+				}
+
+				assertions.RunAssertion(
+					ctx,
+					assertionResult.T(t),
+					terraformOptions,
+					assertion,
+					"plan",
+					planMetadata,
+					assertionContext)
+			})
+		}
+	})
+}
+
+// Runs terraform plan and saves the plan to the plan file of the options, which
+// must be a copy of the test's options, so that its JSON representation can be
+// parsed for structured plan assertions. A failure to
+// parse the plan is only logged, assertions requiring it will fail on their own.
+func planAndShow(ctx context.Context, t *testing.T, planOptions *terraform.Options, replace []string) (string, *terraform.PlanStruct, error) {
+	stdOutErr, err := planE(ctx, t, planOptions, replace)
+	if err != nil {
+		return stdOutErr, nil, err
+	}
+
+	plan, err := cmd.ShowWithStructE(ctx, t, planOptions)
+	if err != nil {
+		t.Logf("WARNING: Failed to parse the JSON representation of the plan: %s", err)
+
+		return stdOutErr, nil, nil
+	}
+
+	return stdOutErr, plan, nil
+}
+
+func runApplyAssertions(
+	t *testing.T,
+	test Test,
+	terraformOptions *terraform.Options,
+	replace []string,
+	skipTests bool,
+	assertionContext *assertions.AssertionContext,
+	testResult *report.Result) {
+	t.Run("Apply", func(t *testing.T) {
+		stepResult := testResult.Start(report.KIND_STEP, "Apply")
+		defer stepResult.Finish(t)
+
+		if skipTests {
+			t.SkipNow()
+		}
+
+		if interrupted, reason := interrupt.interrupted(); interrupted {
+			t.Skipf("INFO: Skipping the step as %s", reason)
+		}
+
+		ctx, cancel := withTimeout(interrupt.context(), test.ApplyAssertions.Timeout, "the apply step")
+		defer cancel()
+
+		if test.WithCleanState {
+			t.Logf("INFO: with_clean_state enabled - running destroy before apply for %s", test.Name)
+			_, err := destroyE(ctx, t, terraformOptions)
+			if err != nil {
+				assertions.ErrorAndSkipf(stepResult.T(t), "ERROR: Failure during terraform destroy: %s", err)
+			}
+		}
+
+		var stdOutErr string
+		var err error
+		if test.ApplyAssertions.EnsureIdempotent {
+			stdOutErr, err = applyAndIdempotentE(ctx, t, terraformOptions, replace)
+		} else {
+			stdOutErr, err = applyE(ctx, t, terraformOptions, replace)
+		}
+		stepResult.SetOutput(stdOutErr)
+
+		if ctx.Err() != nil {
+			assertions.ErrorAndSkipf(stepResult.T(t), "ERROR: %s", context.Cause(ctx))
+		}
+
+		applyMetadata := assertions.ApplyMetadata{CmdOut: stdOutErr, Err: err, TestName: test.Name}
+
+		for _, assertion := range test.ApplyAssertions.Assertions {
+			subTestName := assertion.Type
+			if assertion.Name != "" {
+				subTestName = assertion.Name
+			}
+
+			t.Run(subTestName, func(t *testing.T) {
+				assertionResult := stepResult.StartAssertion(subTestName, assertion.Type)
+				defer assertionResult.Finish(t)
+
+				// Assertions which already ran still run their cleanup.
+				if interrupted, reason := interrupt.interrupted(); interrupted {
+					t.Skipf("INFO: Skipping %s as %s", subTestName, reason)
+				}
+
+				assertions.RunAssertion(
+					ctx,
+					assertionResult.T(t),
+					terraformOptions,
+					assertion,
+					"apply",
+					applyMetadata,
+					assertionContext)
+			})
+		}
+	})
+}
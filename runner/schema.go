@@ -0,0 +1,127 @@
+package runner
+
+import (
+	"path/filepath"
+
+	"github.com/schrodinger/infra-tester/assertions"
+)
+
+type Config struct {
+	TestPlan TestPlan                    `mapstructure:"test_plan"`
+	Metadata map[interface{}]interface{} `mapstructure:",remain"`
+}
+
+type TestPlan struct {
+	Name        string
+	Tests       []Test
+	DestroyVars map[string]interface{} `mapstructure:"destroy_vars"`
+	// Vars passed to all tests, merged with the vars of each test.
+	Vars map[string]interface{}
+	// How test level vars are merged with test plan level vars, either
+	// "deep_merge" (default) or "replace".
+	VarsMerge string `mapstructure:"vars_merge"`
+	// Names of vars whose values are redacted when the vars are logged, on top
+	// of the vars whose name looks sensitive, e.g. "db_password".
+	SensitiveVars []string `mapstructure:"sensitive_vars"`
+	// Run all tests in parallel unless a test opts out.
+	Parallel bool
+	// Maximum number of parallel tests running at the same time. No limit
+	// other than -test.parallel applies if it's not set.
+	MaxParallel int `mapstructure:"max_parallel"`
+	// Whether to "fail" (default) or "skip" when the version of the binary
+	// does not satisfy terraform_version.
+	OnVersionMismatch string `mapstructure:"on_version_mismatch"`
+	// What to do when plan assertions fail, either "skip_apply" (default),
+	// "run_apply" or "abort_plan". Tests can override it.
+	OnPlanFailure string `mapstructure:"on_plan_failure"`
+	// Skip all the remaining tests once a test fails.
+	FailFast bool `mapstructure:"fail_fast"`
+	// Terraform options for all tests, tests can override them.
+	TerraformOptions `mapstructure:",squash"`
+	// Retries and checks of the final destroy.
+	Destroy Destroy
+
+	// Path of the config file the test plan is read from.
+	configPath string
+}
+
+// Returns the directory relative terraform_dir paths of the test plan and its
+// tests are relative to, i.e. the directory of its config file.
+func (testPlan TestPlan) configDir() string {
+	return filepath.Dir(testPlan.configPath)
+}
+
+type Test struct {
+	Name            string
+	WithCleanState  bool `mapstructure:"with_clean_state"`
+	Tags            []string
+	Parallel        *bool
+	Vars            map[string]interface{}
+	VarsMerge       string                     `mapstructure:"vars_merge"`
+	PlanAssertions  assertions.PlanAssertions  `mapstructure:"plan"`
+	ApplyAssertions assertions.ApplyAssertions `mapstructure:"apply"`
+	// Overrides the test plan level Terraform options.
+	TerraformOptions `mapstructure:",squash"`
+	// Expands the test into one test per combination of var values.
+	Matrix *Matrix
+	// Overrides the test plan level on_plan_failure.
+	OnPlanFailure string `mapstructure:"on_plan_failure"`
+}
+
+// Returns whether the test runs in parallel in an isolated working directory.
+// The test level setting takes precedence over the test plan level setting.
+func (test Test) isParallel(testPlan TestPlan) bool {
+	if test.Parallel != nil {
+		return *test.Parallel
+	}
+
+	return testPlan.Parallel
+}
+
+// Returns how the vars of the test are merged with the test plan level vars.
+// The test level setting takes precedence over the test plan level setting.
+func (test Test) varsMerge(testPlan TestPlan) string {
+	if test.VarsMerge != "" {
+		return test.VarsMerge
+	}
+
+	if testPlan.VarsMerge != "" {
+		return testPlan.VarsMerge
+	}
+
+	return VARS_MERGE_DEEP_MERGE
+}
+
+// Returns what to do when the plan assertions of the test fail. The test
+// level setting takes precedence over the test plan level setting.
+func (test Test) onPlanFailure(testPlan TestPlan) string {
+	if test.OnPlanFailure != "" {
+		return test.OnPlanFailure
+	}
+
+	if testPlan.OnPlanFailure != "" {
+		return testPlan.OnPlanFailure
+	}
+
+	return ON_PLAN_FAILURE_SKIP_APPLY
+}
+
+// Returns the version constraint for the binary used by the test. The test
+// level setting takes precedence over the test plan level setting.
+func (test Test) terraformVersion(testPlan TestPlan) string {
+	if test.TerraformVersion != "" {
+		return test.TerraformVersion
+	}
+
+	return testPlan.TerraformVersion
+}
+
+// Returns the resources to replace during plan and apply. The test level
+// setting takes precedence over the test plan level setting.
+func (test Test) replace(testPlan TestPlan) []string {
+	if test.Replace != nil {
+		return test.Replace
+	}
+
+	return testPlan.Replace
+}
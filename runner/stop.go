@@ -0,0 +1,49 @@
+package runner
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+const (
+	ON_PLAN_FAILURE_SKIP_APPLY = "skip_apply"
+	ON_PLAN_FAILURE_RUN_APPLY  = "run_apply"
+	ON_PLAN_FAILURE_ABORT_PLAN = "abort_plan"
+)
+
+// testPlanStop records why the remaining tests of a test plan must not run,
+// e.g. because of fail_fast. It is shared by concurrently running parallel
+// tests.
+type testPlanStop struct {
+	mu     sync.Mutex
+	reason string
+}
+
+// Stops the remaining tests of the test plan. Only the first reason is kept.
+func (s *testPlanStop) stop(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reason == "" {
+		s.reason = reason
+	}
+}
+
+// Returns whether the test plan was stopped, and why.
+func (s *testPlanStop) stopped() (bool, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reason != "", s.reason
+}
+
+// Stops the remaining tests if the test failed and fail_fast is enabled, or if
+// its plan assertions failed and on_plan_failure is abort_plan.
+func (s *testPlanStop) stopAfterTest(t *testing.T, testPlan TestPlan, test Test, abortPlan bool) {
+	if abortPlan {
+		s.stop(fmt.Sprintf("the plan assertions of %s failed and on_plan_failure is %s", test.Name, ON_PLAN_FAILURE_ABORT_PLAN))
+	} else if testPlan.FailFast && t.Failed() {
+		s.stop(fmt.Sprintf("%s failed and fail_fast is enabled", test.Name))
+	}
+}
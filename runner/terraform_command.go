@@ -0,0 +1,21 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Returns a context derived from parent which is done once the timeout is
+// reached, with the given description of what timed out as its cause. Without a
+// timeout the context is only done once it's cancelled or parent is done. The
+// timeout is already validated.
+func withTimeout(parent context.Context, timeout string, description string) (context.Context, context.CancelFunc) {
+	if timeout == "" {
+		return context.WithCancel(parent)
+	}
+
+	duration, _ := time.ParseDuration(timeout)
+
+	return context.WithTimeoutCause(parent, duration, fmt.Errorf("%s timed out after %s", description, duration))
+}
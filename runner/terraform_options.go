@@ -0,0 +1,153 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/schrodinger/infra-tester/assertions"
+	"github.com/schrodinger/infra-tester/utils/cmd"
+)
+
+// Options passed to terratest for running Terraform commands. They can be set
+// at the test plan level and overridden at the test level.
+type TerraformOptions struct {
+	VarFiles      []string               `mapstructure:"var_files"`
+	EnvVars       map[string]string      `mapstructure:"env_vars"`
+	BackendConfig map[string]interface{} `mapstructure:"backend_config"`
+	Targets       []string
+	// Resources to replace, only used for plan and apply.
+	Replace         []string
+	Lock            *bool
+	Parallelism     int
+	TerraformBinary string `mapstructure:"terraform_binary"`
+	// Relative paths are relative to the directory of the config file.
+	TerraformDir string `mapstructure:"terraform_dir"`
+	// Version constraint checked against the binary before running, e.g.
+	// ">= 1.5, < 2.0".
+	TerraformVersion string `mapstructure:"terraform_version"`
+}
+
+// Sets the given options on the terratest options. Options which are not set
+// leave the terratest options untouched.
+func applyTerraformOptions(terraformOptions *terraform.Options, options TerraformOptions, baseDir string) {
+	if options.VarFiles != nil {
+		terraformOptions.VarFiles = append([]string{}, options.VarFiles...)
+	}
+
+	for key, value := range options.EnvVars {
+		if terraformOptions.EnvVars == nil {
+			terraformOptions.EnvVars = map[string]string{}
+		}
+
+		terraformOptions.EnvVars[key] = value
+	}
+
+	for key, value := range options.BackendConfig {
+		if terraformOptions.BackendConfig == nil {
+			terraformOptions.BackendConfig = map[string]interface{}{}
+		}
+
+		terraformOptions.BackendConfig[key] = value
+	}
+
+	if options.Targets != nil {
+		terraformOptions.Targets = append([]string{}, options.Targets...)
+	}
+
+	if options.Lock != nil {
+		terraformOptions.Lock = *options.Lock
+	}
+
+	if options.Parallelism != 0 {
+		terraformOptions.Parallelism = options.Parallelism
+	}
+
+	if options.TerraformBinary != "" {
+		terraformOptions.TerraformBinary = options.TerraformBinary
+	}
+
+	if options.TerraformDir != "" {
+		terraformOptions.TerraformDir = resolveTerraformDir(baseDir, options.TerraformDir)
+	}
+}
+
+func resolveTerraformDir(baseDir string, terraformDir string) string {
+	if filepath.IsAbs(terraformDir) {
+		return terraformDir
+	}
+
+	return filepath.Join(baseDir, terraformDir)
+}
+
+// Returns whether test level options require their own working directory,
+// i.e. the test uses another module than the test plan.
+func (options TerraformOptions) needsOwnWorkingDir() bool {
+	return options.TerraformDir != ""
+}
+
+// Returns whether test level options require the working directory of the
+// test plan to be initialized again, i.e. the test uses another binary.
+func (options TerraformOptions) needsReinit() bool {
+	return options.TerraformBinary != ""
+}
+
+// Returns whether the test needs its own version check, i.e. it uses another
+// binary or version constraint than the test plan.
+func (options TerraformOptions) needsVersionCheck() bool {
+	return options.TerraformBinary != "" || options.TerraformVersion != ""
+}
+
+// Formats the resources to replace as -replace arguments.
+func formatReplaceArgs(replace []string) []string {
+	args := make([]string, 0, len(replace))
+	for _, address := range replace {
+		args = append(args, fmt.Sprintf("-replace=%s", address))
+	}
+
+	return args
+}
+
+// Same as terraform.PlanE, but also replaces the given resources and stops
+// once the context is done.
+func planE(ctx context.Context, t *testing.T, terraformOptions *terraform.Options, replace []string) (string, error) {
+	args := terraform.FormatArgs(terraformOptions, "plan", "-input=false", "-lock=false")
+
+	return cmd.RunTerraformCommandE(ctx, t, terraformOptions, append(args, formatReplaceArgs(replace)...)...)
+}
+
+// Same as terraform.ApplyE, but also replaces the given resources and stops
+// once the context is done.
+func applyE(ctx context.Context, t *testing.T, terraformOptions *terraform.Options, replace []string) (string, error) {
+	args := terraform.FormatArgs(terraformOptions, "apply", "-input=false", "-auto-approve")
+
+	return cmd.RunTerraformCommandE(ctx, t, terraformOptions, append(args, formatReplaceArgs(replace)...)...)
+}
+
+// Same as terraform.ApplyAndIdempotentE, but also replaces the given resources
+// during the apply. The plan checking idempotency does not replace anything.
+func applyAndIdempotentE(ctx context.Context, t *testing.T, terraformOptions *terraform.Options, replace []string) (string, error) {
+	out, err := applyE(ctx, t, terraformOptions, replace)
+	if err != nil {
+		return out, err
+	}
+
+	_, err = cmd.RunTerraformCommandE(ctx, t, terraformOptions, terraform.FormatArgs(terraformOptions, "plan", "-input=false", "-detailed-exitcode")...)
+	exitCode, err := cmd.TerraformExitCode(err)
+	if err != nil {
+		return out, err
+	}
+
+	if exitCode != 0 {
+		return out, fmt.Errorf("terraform configuration not idempotent")
+	}
+
+	return out, nil
+}
+
+// Same as terraform.DestroyE, but stops once the context is done.
+func destroyE(ctx context.Context, t assertions.TestingT, terraformOptions *terraform.Options) (string, error) {
+	return cmd.RunTerraformCommandE(ctx, t, terraformOptions, terraform.FormatArgs(terraformOptions, "destroy", "-auto-approve", "-input=false")...)
+}
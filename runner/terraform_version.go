@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/hashicorp/go-version"
+	"github.com/schrodinger/infra-tester/assertions"
+	"github.com/schrodinger/infra-tester/utils/cmd"
+)
+
+const (
+	ON_VERSION_MISMATCH_FAIL = "fail"
+	ON_VERSION_MISMATCH_SKIP = "skip"
+)
+
+// Matches the version in the first line printed by `terraform version`, e.g.
+// "Terraform v1.6.0" or "OpenTofu v1.6.2".
+var terraformVersionRegexp = regexp.MustCompile(`v(\d+\.\d+\.\d+\S*)`)
+
+// Returns the version of the binary used to run Terraform commands. The command
+// is stopped once the run is interrupted.
+func getTerraformVersion(t *testing.T, terraformOptions *terraform.Options) (*version.Version, error) {
+	out, err := cmd.RunTerraformCommandE(interrupt.context(), t, terraformOptions, "version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the version of %s: %s", terraformOptions.TerraformBinary, err)
+	}
+
+	matches := terraformVersionRegexp.FindStringSubmatch(out)
+	if matches == nil {
+		return nil, fmt.Errorf("failed to parse the version of %s from: %s", terraformOptions.TerraformBinary, out)
+	}
+
+	return version.NewVersion(matches[1])
+}
+
+// Checks the version of the binary used by the given options against the
+// version constraint. On a mismatch the test either fails or is skipped
+// depending on onMismatch. Without a constraint the version is only logged.
+func checkTerraformVersion(
+	t *testing.T,
+	rt assertions.TestingT,
+	terraformOptions *terraform.Options,
+	constraint string,
+	onMismatch string) {
+	// Copy the options so that resolving the default binary doesn't modify them.
+	versionOptions, err := terraformOptions.Clone()
+	if err != nil {
+		rt.Fatalf("ERROR: Failed to copy terraform options: %s", err)
+	}
+
+	terraformVersion, err := getTerraformVersion(t, versionOptions)
+	if err != nil {
+		if constraint == "" {
+			t.Logf("WARNING: %s", err)
+
+			return
+		}
+
+		rt.Fatalf("ERROR: %s", err)
+	}
+
+	t.Logf("INFO: Using %s version %s", versionOptions.TerraformBinary, terraformVersion)
+
+	if constraint == "" {
+		return
+	}
+
+	// The constraint is already validated.
+	constraints, _ := version.NewConstraint(constraint)
+	if constraints.Check(terraformVersion) {
+		return
+	}
+
+	message := fmt.Sprintf("%s version %s does not satisfy the version constraint '%s'", versionOptions.TerraformBinary, terraformVersion, constraint)
+	if onMismatch == ON_VERSION_MISMATCH_SKIP {
+		t.Skipf("INFO: Skipping as %s", message)
+	}
+
+	rt.Fatalf("ERROR: %s", message)
+}
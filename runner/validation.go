@@ -0,0 +1,238 @@
+package runner
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/schrodinger/infra-tester/assertions"
+)
+
+func validateVarsMerge(varsMerge string) error {
+	if varsMerge != "" && varsMerge != VARS_MERGE_DEEP_MERGE && varsMerge != VARS_MERGE_REPLACE {
+		return fmt.Errorf("vars_merge '%s' is invalid, it must be either '%s' or '%s'", varsMerge, VARS_MERGE_DEEP_MERGE, VARS_MERGE_REPLACE)
+	}
+
+	return nil
+}
+
+func validateOnPlanFailure(onPlanFailure string) error {
+	switch onPlanFailure {
+	case "", ON_PLAN_FAILURE_SKIP_APPLY, ON_PLAN_FAILURE_RUN_APPLY, ON_PLAN_FAILURE_ABORT_PLAN:
+		return nil
+	}
+
+	return fmt.Errorf("on_plan_failure '%s' is invalid, it must be one of '%s', '%s' or '%s'",
+		onPlanFailure, ON_PLAN_FAILURE_SKIP_APPLY, ON_PLAN_FAILURE_RUN_APPLY, ON_PLAN_FAILURE_ABORT_PLAN)
+}
+
+func validateStepTimeout(step string, timeout string) error {
+	if timeout == "" {
+		return nil
+	}
+
+	if parsed, err := time.ParseDuration(timeout); err != nil || parsed <= 0 {
+		return fmt.Errorf("%s step timeout '%s' is not a valid duration", step, timeout)
+	}
+
+	return nil
+}
+
+// Validates the Terraform options of a test plan or test, whose relative
+// terraform_dir is relative to baseDir.
+func validateTerraformOptions(options TerraformOptions, baseDir string) error {
+	for _, varFile := range options.VarFiles {
+		if strings.TrimSpace(varFile) == "" {
+			return fmt.Errorf("var_files must not contain empty paths")
+		}
+	}
+
+	for name := range options.EnvVars {
+		if strings.TrimSpace(name) == "" || strings.Contains(name, "=") {
+			return fmt.Errorf("env_vars name '%s' is invalid - names must not be empty or contain '='", name)
+		}
+	}
+
+	for key := range options.BackendConfig {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("backend_config must not contain empty keys")
+		}
+	}
+
+	for _, target := range options.Targets {
+		if strings.TrimSpace(target) == "" {
+			return fmt.Errorf("targets must not contain empty addresses")
+		}
+	}
+
+	for _, address := range options.Replace {
+		if strings.TrimSpace(address) == "" {
+			return fmt.Errorf("replace must not contain empty addresses")
+		}
+	}
+
+	if options.Parallelism < 0 {
+		return fmt.Errorf("parallelism must not be negative")
+	}
+
+	if options.TerraformBinary != "" {
+		if _, err := exec.LookPath(options.TerraformBinary); err != nil {
+			return fmt.Errorf("terraform_binary '%s' is invalid: %s", options.TerraformBinary, err)
+		}
+	}
+
+	if options.TerraformVersion != "" {
+		if _, err := version.NewConstraint(options.TerraformVersion); err != nil {
+			return fmt.Errorf("terraform_version '%s' is not a valid version constraint: %s", options.TerraformVersion, err)
+		}
+	}
+
+	if options.TerraformDir != "" {
+		dir := resolveTerraformDir(baseDir, options.TerraformDir)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("terraform_dir '%s' is not a directory", dir)
+		}
+	}
+
+	return nil
+}
+
+func validateTest(test Test, testPlan TestPlan, assertionContext *assertions.AssertionContext) error {
+	if err := validateVarsMerge(test.VarsMerge); err != nil {
+		return err
+	}
+
+	if err := validateOnPlanFailure(test.OnPlanFailure); err != nil {
+		return err
+	}
+
+	if err := validateTerraformOptions(test.TerraformOptions, testPlan.configDir()); err != nil {
+		return err
+	}
+
+	// Parallel tests always use a local backend, so their backend config would
+	// silently be ignored.
+	if test.BackendConfig != nil && test.isParallel(testPlan) {
+		return fmt.Errorf("backend_config can't be set for parallel tests, as they always use a local backend")
+	}
+
+	// Sequential tests of the same module share the state of the test plan,
+	// which another backend config would silently replace.
+	if test.BackendConfig != nil && test.TerraformDir == "" {
+		return fmt.Errorf("backend_config can only be set for sequential tests with their own terraform_dir, " +
+			"as the other sequential tests share the state of the test plan")
+	}
+
+	for _, tag := range test.Tags {
+		if strings.TrimSpace(tag) == "" || strings.Contains(tag, ",") {
+			return fmt.Errorf("tag '%s' is invalid - tags must not be empty or contain commas", tag)
+		}
+	}
+
+	vars := mergeVars(testPlan.Vars, test.Vars, test.varsMerge(testPlan))
+
+	if err := validateStepTimeout("plan", test.PlanAssertions.Timeout); err != nil {
+		return err
+	}
+
+	if err := validateStepTimeout("apply", test.ApplyAssertions.Timeout); err != nil {
+		return err
+	}
+
+	// Test level env_vars are merged key by key like at run time.
+	envVars := map[string]string{}
+	for _, options := range []TerraformOptions{testPlan.TerraformOptions, test.TerraformOptions} {
+		for name, value := range options.EnvVars {
+			envVars[name] = value
+		}
+	}
+
+	templateData := assertions.TemplateData{Vars: vars, EnvVars: envVars}
+
+	for _, assertion := range test.PlanAssertions.Assertions {
+		// Output references can only be resolved at run time, vars and env
+		// references are resolved so that the inputs can be validated.
+		resolvedAssertion, err := assertions.ResolveTemplates(assertion, "plan", templateData)
+		if err != nil {
+			return fmt.Errorf("assertion '%s' for plan step failed validation because - %s", assertion.Type, err)
+		}
+
+		if err := assertions.ValidateAssertion(resolvedAssertion, "plan", assertionContext); err != nil {
+			return fmt.Errorf("assertion '%s' for plan step failed validation because - %s", assertion.Type, err)
+		}
+	}
+
+	for _, assertion := range test.ApplyAssertions.Assertions {
+		resolvedAssertion, err := assertions.ResolveTemplates(assertion, "apply", templateData)
+		if err != nil {
+			return fmt.Errorf("assertion '%s' for apply step failed validation because - %s", assertion.Type, err)
+		}
+
+		if err := assertions.ValidateAssertion(resolvedAssertion, "apply", assertionContext); err != nil {
+			return fmt.Errorf("assertion '%s' for apply step failed validation because - %s", assertion.Type, err)
+		}
+	}
+
+	return nil
+}
+
+func validateTests(testPlan TestPlan, assertionContext *assertions.AssertionContext) error {
+	validatedTests := make(map[string]Test)
+
+	if err := validateVarsMerge(testPlan.VarsMerge); err != nil {
+		return err
+	}
+
+	if err := validateOnPlanFailure(testPlan.OnPlanFailure); err != nil {
+		return err
+	}
+
+	if err := validateTerraformOptions(testPlan.TerraformOptions, testPlan.configDir()); err != nil {
+		return err
+	}
+
+	if testPlan.OnVersionMismatch != "" && testPlan.OnVersionMismatch != ON_VERSION_MISMATCH_FAIL && testPlan.OnVersionMismatch != ON_VERSION_MISMATCH_SKIP {
+		return fmt.Errorf("on_version_mismatch '%s' is invalid, it must be either '%s' or '%s'", testPlan.OnVersionMismatch, ON_VERSION_MISMATCH_FAIL, ON_VERSION_MISMATCH_SKIP)
+	}
+
+	if err := validateDestroy(testPlan.Destroy); err != nil {
+		return err
+	}
+
+	for _, name := range testPlan.SensitiveVars {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("sensitive_vars must not contain empty names")
+		}
+	}
+
+	if testPlan.MaxParallel < 0 {
+		return fmt.Errorf("max_parallel must not be negative")
+	}
+
+	for _, test := range testPlan.Tests {
+		testName := test.Name
+
+		if test.Name == "" {
+			return fmt.Errorf("test name is not defined")
+		}
+
+		// check for duplicate test names
+		if _, ok := validatedTests[testName]; ok {
+			return fmt.Errorf("test name '%s' is already defined previously - tests with same name are not allowed", testName)
+		}
+
+		if err := validateTest(test, testPlan, assertionContext); err != nil {
+			return fmt.Errorf("test '%s' failed validation: %s", testName, err)
+		}
+
+		validatedTests[testName] = test
+	}
+
+	log.Println("INFO: All tests and assertions are valid.")
+
+	return nil
+}
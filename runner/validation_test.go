@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTestBackendConfig(t *testing.T) {
+	parallel := true
+	sequential := false
+	backendConfig := map[string]interface{}{"key": "test.tfstate"}
+
+	tests := []struct {
+		name string
+		test Test
+		err  string
+	}{
+		{
+			name: "sequential tests with their own terraform_dir can set backend_config",
+			test: Test{Parallel: &sequential, TerraformOptions: TerraformOptions{BackendConfig: backendConfig, TerraformDir: "."}},
+		},
+		{
+			name: "sequential tests sharing the module of the test plan can't set backend_config",
+			test: Test{Parallel: &sequential, TerraformOptions: TerraformOptions{BackendConfig: backendConfig}},
+			err:  "backend_config can only be set for sequential tests with their own terraform_dir",
+		},
+		{
+			name: "parallel tests can't set backend_config",
+			test: Test{Parallel: &parallel, TerraformOptions: TerraformOptions{BackendConfig: backendConfig}},
+			err:  "backend_config can't be set for parallel tests",
+		},
+		{
+			name: "parallel tests with their own terraform_dir can't set backend_config",
+			test: Test{Parallel: &parallel, TerraformOptions: TerraformOptions{BackendConfig: backendConfig, TerraformDir: "."}},
+			err:  "backend_config can't be set for parallel tests",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateTest(test.test, TestPlan{}, nil)
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+const (
+	VARS_MERGE_DEEP_MERGE = "deep_merge"
+	VARS_MERGE_REPLACE    = "replace"
+)
+
+// Builds the terraform options for the given test from the base options. The
+// base options are never modified, so tests don't leak options or vars into
+// each other.
+func buildTestOptions(
+	t *testing.T,
+	baseTerraformOptions *terraform.Options,
+	testPlan TestPlan,
+	test Test) (*terraform.Options, error) {
+	terraformOptions, err := baseTerraformOptions.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy terraform options: %s", err)
+	}
+
+	applyTerraformOptions(terraformOptions, test.TerraformOptions, testPlan.configDir())
+	terraformOptions.Vars = mergeVars(testPlan.Vars, test.Vars, test.varsMerge(testPlan))
+
+	t.Logf("INFO: Using vars for %s: %s", test.Name, formatVars(terraformOptions.Vars, testPlan.SensitiveVars))
+
+	return terraformOptions, nil
+}
+
+// Value logged in place of the value of sensitive vars.
+const REDACTED_VALUE = "(sensitive value)"
+
+// Parts of var names which mark a var as sensitive, compared in lower case.
+var sensitiveVarNameParts = []string{"password", "passwd", "secret", "token", "credential", "private_key", "api_key", "access_key"}
+
+// Formats the vars for logging, so that stale or unexpected values can be
+// spotted. The values of vars marked as sensitive with sensitive_vars, or
+// whose name looks sensitive, are redacted at any depth.
+func formatVars(vars map[string]interface{}, sensitiveVars []string) string {
+	redacted := redactVars(vars, sensitiveVars)
+
+	formatted, err := json.Marshal(redacted)
+	if err != nil {
+		return fmt.Sprintf("%+v", redacted)
+	}
+
+	return string(formatted)
+}
+
+func redactVars(vars map[string]interface{}, sensitiveVars []string) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(vars))
+	for name, value := range vars {
+		if isSensitiveVar(name, sensitiveVars) {
+			redacted[name] = REDACTED_VALUE
+		} else {
+			redacted[name] = redactValue(value, sensitiveVars)
+		}
+	}
+
+	return redacted
+}
+
+func redactValue(value interface{}, sensitiveVars []string) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		return redactVars(typedValue, sensitiveVars)
+	case []interface{}:
+		redacted := make([]interface{}, len(typedValue))
+		for i, item := range typedValue {
+			redacted[i] = redactValue(item, sensitiveVars)
+		}
+
+		return redacted
+	default:
+		return value
+	}
+}
+
+func isSensitiveVar(name string, sensitiveVars []string) bool {
+	for _, sensitiveVar := range sensitiveVars {
+		if name == sensitiveVar {
+			return true
+		}
+	}
+
+	lowerName := strings.ToLower(name)
+	for _, part := range sensitiveVarNameParts {
+		if strings.Contains(lowerName, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Merges the test plan level vars with the test level vars. With the replace
+// strategy the test level vars replace the test plan level vars entirely if
+// defined, otherwise maps are merged recursively with the test level values
+// taking precedence.
+func mergeVars(testPlanVars map[string]interface{}, testVars map[string]interface{}, strategy string) map[string]interface{} {
+	if strategy == VARS_MERGE_REPLACE && testVars != nil {
+		return deepCopyVars(testVars)
+	}
+
+	merged := deepCopyVars(testPlanVars)
+	for key, testValue := range testVars {
+		testPlanValue, ok := merged[key]
+		if !ok {
+			merged[key] = deepCopyValue(testValue)
+			continue
+		}
+
+		testPlanMap, isTestPlanMap := testPlanValue.(map[string]interface{})
+		testMap, isTestMap := testValue.(map[string]interface{})
+		if isTestPlanMap && isTestMap {
+			merged[key] = mergeVars(testPlanMap, testMap, strategy)
+		} else {
+			merged[key] = deepCopyValue(testValue)
+		}
+	}
+
+	return merged
+}
+
+func deepCopyVars(vars map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(vars))
+	for key, value := range vars {
+		copied[key] = deepCopyValue(value)
+	}
+
+	return copied
+}
+
+func deepCopyValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		return deepCopyVars(typedValue)
+	case []interface{}:
+		copied := make([]interface{}, len(typedValue))
+		for i, item := range typedValue {
+			copied[i] = deepCopyValue(item)
+		}
+
+		return copied
+	default:
+		return value
+	}
+}
@@ -0,0 +1,163 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeVars(t *testing.T) {
+	tests := []struct {
+		name         string
+		testPlanVars map[string]interface{}
+		testVars     map[string]interface{}
+		strategy     string
+		expected     map[string]interface{}
+	}{
+		{
+			name:         "test vars are added to test plan vars",
+			testPlanVars: map[string]interface{}{"region": "us"},
+			testVars:     map[string]interface{}{"size": "small"},
+			strategy:     VARS_MERGE_DEEP_MERGE,
+			expected:     map[string]interface{}{"region": "us", "size": "small"},
+		},
+		{
+			name:         "test vars take precedence",
+			testPlanVars: map[string]interface{}{"region": "us"},
+			testVars:     map[string]interface{}{"region": "eu"},
+			strategy:     VARS_MERGE_DEEP_MERGE,
+			expected:     map[string]interface{}{"region": "eu"},
+		},
+		{
+			name: "maps are merged recursively",
+			testPlanVars: map[string]interface{}{
+				"tags": map[string]interface{}{"team": "infra", "env": "test", "nested": map[string]interface{}{"a": 1}},
+			},
+			testVars: map[string]interface{}{
+				"tags": map[string]interface{}{"env": "prod", "nested": map[string]interface{}{"b": 2}},
+			},
+			strategy: VARS_MERGE_DEEP_MERGE,
+			expected: map[string]interface{}{
+				"tags": map[string]interface{}{"team": "infra", "env": "prod", "nested": map[string]interface{}{"a": 1, "b": 2}},
+			},
+		},
+		{
+			name:         "lists are replaced",
+			testPlanVars: map[string]interface{}{"zones": []interface{}{"a", "b"}},
+			testVars:     map[string]interface{}{"zones": []interface{}{"c"}},
+			strategy:     VARS_MERGE_DEEP_MERGE,
+			expected:     map[string]interface{}{"zones": []interface{}{"c"}},
+		},
+		{
+			name:         "a map replaces a scalar",
+			testPlanVars: map[string]interface{}{"tags": "none"},
+			testVars:     map[string]interface{}{"tags": map[string]interface{}{"env": "test"}},
+			strategy:     VARS_MERGE_DEEP_MERGE,
+			expected:     map[string]interface{}{"tags": map[string]interface{}{"env": "test"}},
+		},
+		{
+			name:         "replace uses only the test vars",
+			testPlanVars: map[string]interface{}{"region": "us", "tags": map[string]interface{}{"team": "infra"}},
+			testVars:     map[string]interface{}{"tags": map[string]interface{}{"env": "test"}},
+			strategy:     VARS_MERGE_REPLACE,
+			expected:     map[string]interface{}{"tags": map[string]interface{}{"env": "test"}},
+		},
+		{
+			name:         "replace keeps the test plan vars if the test has no vars",
+			testPlanVars: map[string]interface{}{"region": "us"},
+			testVars:     nil,
+			strategy:     VARS_MERGE_REPLACE,
+			expected:     map[string]interface{}{"region": "us"},
+		},
+		{
+			name:         "replace with empty test vars uses no vars",
+			testPlanVars: map[string]interface{}{"region": "us"},
+			testVars:     map[string]interface{}{},
+			strategy:     VARS_MERGE_REPLACE,
+			expected:     map[string]interface{}{},
+		},
+		{
+			name:         "no vars at all",
+			testPlanVars: nil,
+			testVars:     nil,
+			strategy:     VARS_MERGE_DEEP_MERGE,
+			expected:     map[string]interface{}{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, mergeVars(test.testPlanVars, test.testVars, test.strategy))
+		})
+	}
+}
+
+func TestMergeVarsDoesNotModifyInputs(t *testing.T) {
+	testPlanVars := map[string]interface{}{"tags": map[string]interface{}{"team": "infra"}}
+	testVars := map[string]interface{}{"tags": map[string]interface{}{"env": "test"}, "zones": []interface{}{"a"}}
+
+	merged := mergeVars(testPlanVars, testVars, VARS_MERGE_DEEP_MERGE)
+	merged["tags"].(map[string]interface{})["team"] = "changed"
+	merged["zones"].([]interface{})[0] = "changed"
+
+	assert.Equal(t, map[string]interface{}{"tags": map[string]interface{}{"team": "infra"}}, testPlanVars)
+	assert.Equal(t, map[string]interface{}{"tags": map[string]interface{}{"env": "test"}, "zones": []interface{}{"a"}}, testVars)
+}
+
+func TestFormatVars(t *testing.T) {
+	tests := []struct {
+		name          string
+		vars          map[string]interface{}
+		sensitiveVars []string
+		expected      string
+	}{
+		{
+			name:     "values are logged",
+			vars:     map[string]interface{}{"region": "us", "count": 2, "zones": []interface{}{"a", "b"}},
+			expected: `{"count":2,"region":"us","zones":["a","b"]}`,
+		},
+		{
+			name:     "vars whose name looks sensitive are redacted",
+			vars:     map[string]interface{}{"region": "us", "DB_Password": "hunter2", "api_token": "abc"},
+			expected: `{"DB_Password":"(sensitive value)","api_token":"(sensitive value)","region":"us"}`,
+		},
+		{
+			name:          "vars marked as sensitive are redacted",
+			vars:          map[string]interface{}{"region": "us", "license": "abc"},
+			sensitiveVars: []string{"license"},
+			expected:      `{"license":"(sensitive value)","region":"us"}`,
+		},
+		{
+			name:     "sensitive vars are redacted as a whole",
+			vars:     map[string]interface{}{"credentials": map[string]interface{}{"user": "admin"}},
+			expected: `{"credentials":"(sensitive value)"}`,
+		},
+		{
+			name: "nested sensitive keys are redacted",
+			vars: map[string]interface{}{
+				"db":    map[string]interface{}{"name": "app", "password": "hunter2"},
+				"users": []interface{}{map[string]interface{}{"name": "a", "access_key": "abc"}},
+			},
+			expected: `{"db":{"name":"app","password":"(sensitive value)"},"users":[{"access_key":"(sensitive value)","name":"a"}]}`,
+		},
+		{
+			name:     "no vars",
+			vars:     nil,
+			expected: `{}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, formatVars(test.vars, test.sensitiveVars))
+		})
+	}
+}
+
+func TestFormatVarsDoesNotModifyVars(t *testing.T) {
+	vars := map[string]interface{}{"db": map[string]interface{}{"password": "hunter2"}}
+
+	formatVars(vars, nil)
+
+	assert.Equal(t, map[string]interface{}{"db": map[string]interface{}{"password": "hunter2"}}, vars)
+}
@@ -1,6 +1,10 @@
 package cmd
 
-import "os/exec"
+import (
+	"bytes"
+	"os/exec"
+	"time"
+)
 
 type CommandResult interface {
 	// Returns the exit code of the command.
@@ -15,15 +19,24 @@ type CommandResult interface {
 	// Returns the stderr of the command.
 	Stderr() string
 
+	// Returns the stdout and stderr of the command in the order they were
+	// written.
+	CombinedOutput() string
+
+	// Returns how long the command ran.
+	Duration() time.Duration
+
 	// Returns the command that was executed. This can be helpful for debugging.
 	ExecutedCommand() string
 }
 
 type commandResult struct {
-	cmd    *exec.Cmd
-	err    error
-	stdout string
-	stderr string
+	cmd      *exec.Cmd
+	err      error
+	stdout   bytes.Buffer
+	stderr   bytes.Buffer
+	combined bytes.Buffer
+	duration time.Duration
 }
 
 func (c *commandResult) ExitCode() int {
@@ -35,11 +48,19 @@ func (c *commandResult) Error() error {
 }
 
 func (c *commandResult) Stdout() string {
-	return c.stdout
+	return c.stdout.String()
 }
 
 func (c *commandResult) Stderr() string {
-	return c.stderr
+	return c.stderr.String()
+}
+
+func (c *commandResult) CombinedOutput() string {
+	return c.combined.String()
+}
+
+func (c *commandResult) Duration() time.Duration {
+	return c.duration
 }
 
 func (c *commandResult) ExecutedCommand() string {
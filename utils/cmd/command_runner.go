@@ -2,44 +2,211 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"strings"
+	"sync"
+	"time"
 )
 
+// Time a command is given to exit after it is interrupted because its context
+// is done, before it is killed.
+const INTERRUPT_WAIT_DELAY = 10 * time.Second
+
 type CommandRunner interface {
 	// Returns the path to the executable for the given command.
 	LookPath(commandName string) (string, error)
 
+	// Runs the given command and returns the result. The command runs in its
+	// own process group, and is interrupted once the context is done.
+	Run(ctx context.Context, command Command) CommandResult
+
 	// Runs the given command with the given args and returns the result.
 	RunCommand(command string, args ...string) CommandResult
+
+	// Same as RunCommand, but writes the given string to the stdin of the
+	// command.
+	RunCommandWithStdin(stdin string, command string, args ...string) CommandResult
 }
 
-type cmdRunner struct{}
+// Logger receives the output of a command line by line while it runs, e.g. a
+// *testing.T.
+type Logger interface {
+	Logf(format string, args ...any)
+}
+
+// Command describes a command to run with CommandRunner.Run.
+type Command struct {
+	Name string
+	Args []string
+	// Working directory of the command, the current directory if empty.
+	Dir string
+	// Environment variables set on top of the environment of infra-tester.
+	Env map[string]string
+	// Don't inherit the environment of infra-tester, only use Env.
+	ClearEnv bool
+	// Read by the command as its stdin, no input if nil.
+	Stdin io.Reader
+	// Logs every line of stdout and stderr while the command runs, prefixed
+	// with LogPrefix and the name of the stream. The output is still captured
+	// in the result.
+	Log       Logger
+	LogPrefix string
+	// Time the command is given to exit after it is interrupted, before it is
+	// killed. Defaults to INTERRUPT_WAIT_DELAY.
+	WaitDelay time.Duration
+}
+
+// Returns the prefix of the logged lines of the given stream.
+func (c Command) logPrefix(stream string) string {
+	if c.LogPrefix == "" {
+		return stream + ": "
+	}
+
+	return fmt.Sprintf("%s %s: ", c.LogPrefix, stream)
+}
+
+type cmdRunner struct {
+	timeout time.Duration
+}
 
 // Creates a new CommandRunner that can be used to run commands.
 func NewCmdRunner() CommandRunner {
 	return cmdRunner{}
 }
 
+// Creates a new CommandRunner which stops commands running longer than the
+// given timeout. Commands are interrupted first, and killed if they don't exit
+// within INTERRUPT_WAIT_DELAY. A timeout of 0 means no timeout.
+func NewCmdRunnerWithTimeout(timeout time.Duration) CommandRunner {
+	return cmdRunner{timeout: timeout}
+}
+
 func (c cmdRunner) LookPath(commandName string) (string, error) {
 	return exec.LookPath(commandName)
 }
 
 func (c cmdRunner) RunCommand(command string, args ...string) CommandResult {
-	cmd := exec.Command(command, args...)
+	return c.Run(context.Background(), Command{Name: command, Args: args})
+}
+
+func (c cmdRunner) RunCommandWithStdin(stdin string, command string, args ...string) CommandResult {
+	return c.Run(context.Background(), Command{Name: command, Args: args, Stdin: strings.NewReader(stdin)})
+}
+
+func (c cmdRunner) Run(ctx context.Context, command Command) CommandResult {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, c.timeout, fmt.Errorf("command timed out after %s", c.timeout))
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, command.Name, command.Args...)
+	cmd.Dir = command.Dir
+	cmd.Stdin = command.Stdin
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return interruptProcess(cmd)
+	}
+
+	cmd.WaitDelay = INTERRUPT_WAIT_DELAY
+	if command.WaitDelay > 0 {
+		cmd.WaitDelay = command.WaitDelay
+	}
+
+	if command.ClearEnv || len(command.Env) > 0 {
+		if !command.ClearEnv {
+			cmd.Env = os.Environ()
+		}
+
+		for key, value := range command.Env {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+
+	result := &commandResult{cmd: cmd}
 
-	var stdoutBytes, stderrBytes bytes.Buffer
-	cmd.Stdout = &stdoutBytes
-	cmd.Stderr = &stderrBytes
+	// Both streams are also written to the combined output, which is shared
+	// by the goroutines copying them.
+	combined := &lockedWriter{w: &result.combined}
+	stdout := []io.Writer{&result.stdout, combined}
+	stderr := []io.Writer{&result.stderr, combined}
+
+	var stdoutLines, stderrLines *lineLogger
+	if command.Log != nil {
+		stdoutLines = &lineLogger{log: command.Log, prefix: command.logPrefix("stdout")}
+		stderrLines = &lineLogger{log: command.Log, prefix: command.logPrefix("stderr")}
+		stdout = append(stdout, stdoutLines)
+		stderr = append(stderr, stderrLines)
+	}
 
+	cmd.Stdout = io.MultiWriter(stdout...)
+	cmd.Stderr = io.MultiWriter(stderr...)
+
+	startedAt := time.Now()
 	err := cmd.Run()
+	result.duration = time.Since(startedAt)
+
+	if command.Log != nil {
+		stdoutLines.flush()
+		stderrLines.flush()
+	}
 
-	stdout := stdoutBytes.String()
-	stderr := stderrBytes.String()
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("%s: %w", context.Cause(ctx), err)
+	}
+
+	result.err = err
+
+	return result
+}
+
+// lockedWriter serializes the writes of concurrently copied streams.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.w.Write(p)
+}
+
+// lineLogger logs every complete line written to it. The last line is only
+// logged once flushed if it doesn't end with a newline.
+type lineLogger struct {
+	log    Logger
+	prefix string
+	buf    bytes.Buffer
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.buf.Write(p)
+
+	for {
+		line, err := l.buf.ReadString('\n')
+		if err != nil {
+			// Keep the incomplete line until the rest is written.
+			l.buf.Reset()
+			l.buf.WriteString(line)
+
+			break
+		}
+
+		l.log.Logf("%s%s", l.prefix, strings.TrimRight(line, "\r\n"))
+	}
+
+	return len(p), nil
+}
 
-	return &commandResult{
-		cmd:    cmd,
-		err:    err,
-		stdout: stdout,
-		stderr: stderr,
+func (l *lineLogger) flush() {
+	if l.buf.Len() > 0 {
+		l.log.Logf("%s%s", l.prefix, l.buf.String())
+		l.buf.Reset()
 	}
 }
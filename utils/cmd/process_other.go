@@ -0,0 +1,15 @@
+//go:build !unix
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Process groups are only supported on Unix.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func interruptProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Signal(os.Interrupt)
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package cmd
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Starts the command in its own process group, so that a SIGINT sent by a
+// terminal to the process group of infra-tester doesn't reach it. It only gets
+// the interrupt sent once its context is done, so exactly one.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// Interrupts the process group of the command like a terminal does, so that
+// the processes started by the command are interrupted as well.
+func interruptProcess(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGINT)
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// Time Terraform is given to stop gracefully once it is interrupted because its
+// context is done, e.g. to release the state lock, before it is killed.
+const TERRAFORM_INTERRUPT_WAIT_DELAY = 2 * time.Minute
+
+// Same as terraform.RunTerraformCommandE, but runs the command with a
+// CommandRunner and stops it once the context is done. Terraform is
+// interrupted first so that it can stop gracefully, and killed if it doesn't
+// exit within TERRAFORM_INTERRUPT_WAIT_DELAY. Returns stdout and stderr
+// combined, and an error which wraps the exit error of the command, if any.
+func RunTerraformCommandE(ctx context.Context, t testing.TestingT, terraformOptions *terraform.Options, additionalArgs ...string) (string, error) {
+	res, err := runTerraformCommandWithRetry(ctx, t, terraformOptions, additionalArgs...)
+	if res == nil {
+		return "", err
+	}
+
+	return res.CombinedOutput(), err
+}
+
+// Same as RunTerraformCommandE, but only returns stdout, e.g. for commands
+// printing JSON.
+func RunTerraformCommandAndGetStdoutE(ctx context.Context, t testing.TestingT, terraformOptions *terraform.Options, additionalArgs ...string) (string, error) {
+	res, err := runTerraformCommandWithRetry(ctx, t, terraformOptions, additionalArgs...)
+	if res == nil {
+		return "", err
+	}
+
+	return res.Stdout(), err
+}
+
+// Same as terraform.ShowE, but stops terraform show once the context is done.
+func ShowE(ctx context.Context, t testing.TestingT, terraformOptions *terraform.Options) (string, error) {
+	args := []string{"show", "-no-color", "-json"}
+	if terraformOptions.PlanFilePath != "" {
+		args = append(args, terraformOptions.PlanFilePath)
+	}
+
+	return RunTerraformCommandAndGetStdoutE(ctx, t, terraformOptions, args...)
+}
+
+// Same as terraform.ShowWithStructE, but stops terraform show once the context
+// is done.
+func ShowWithStructE(ctx context.Context, t testing.TestingT, terraformOptions *terraform.Options) (*terraform.PlanStruct, error) {
+	out, err := ShowE(ctx, t, terraformOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return terraform.ParsePlanJSON(out)
+}
+
+// Same as terraform.OutputAllE, but stops terraform output once the context is
+// done.
+func OutputAllE(ctx context.Context, t testing.TestingT, terraformOptions *terraform.Options) (map[string]interface{}, error) {
+	out, err := RunTerraformCommandAndGetStdoutE(ctx, t, terraformOptions, "output", "-no-color", "-json")
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := map[string]struct {
+		Value interface{} `json:"value"`
+	}{}
+	if err := json.Unmarshal([]byte(out), &outputs); err != nil {
+		return nil, fmt.Errorf("failed to parse the outputs: %s", err)
+	}
+
+	values := make(map[string]interface{}, len(outputs))
+	for name, output := range outputs {
+		values[name] = output.Value
+	}
+
+	return values, nil
+}
+
+// Same as terraform.OutputE, but stops terraform output once the context is
+// done. Like with terratest, the value is formatted as a string, so it's only
+// meant for outputs of primitive types.
+func OutputE(ctx context.Context, t testing.TestingT, terraformOptions *terraform.Options, name string) (string, error) {
+	out, err := RunTerraformCommandAndGetStdoutE(ctx, t, terraformOptions, "output", "-no-color", "-json", name)
+	if err != nil {
+		return "", err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(out), &value); err != nil {
+		return "", fmt.Errorf("failed to parse the output %s: %s", name, err)
+	}
+
+	return fmt.Sprintf("%v", value), nil
+}
+
+// Returns the exit code of an error returned by RunTerraformCommandE, or an
+// error if the command didn't run until it exited.
+func TerraformExitCode(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+
+	return 0, err
+}
+
+// Runs the command with the retries of the terraform options like terratest
+// does, until it passes or the context is done. Returns the result of the last
+// run, nil if the command never ran.
+func runTerraformCommandWithRetry(ctx context.Context, t testing.TestingT, terraformOptions *terraform.Options, additionalArgs ...string) (CommandResult, error) {
+	options, args := terraform.GetCommonOptions(terraformOptions, additionalArgs...)
+	description := fmt.Sprintf("%s %v", options.TerraformBinary, args)
+
+	var res CommandResult
+	_, err := retry.DoWithRetryableErrorsE(t, description, options.RetryableTerraformErrors, options.MaxRetries, options.TimeBetweenRetries, func() (string, error) {
+		// Retrying is pointless once the context is done.
+		if err := ctx.Err(); err != nil {
+			return "", retry.FatalError{Underlying: err}
+		}
+
+		res = runTerraformCommand(ctx, t, options, args)
+		if err := res.Error(); err != nil {
+			return res.CombinedOutput(), fmt.Errorf("error while running command: %w; %s", err, res.Stderr())
+		}
+
+		return res.CombinedOutput(), nil
+	})
+
+	var fatalErr retry.FatalError
+	if errors.As(err, &fatalErr) {
+		err = fatalErr.Underlying
+	}
+
+	if err != nil && ctx.Err() != nil {
+		return res, fmt.Errorf("'%s' was stopped: %w", description, context.Cause(ctx))
+	}
+
+	return res, err
+}
+
+// Runs the command once, logging its stdout and stderr line by line while it
+// runs. Unlike with terratest, Terraform doesn't get the stdin of infra-tester,
+// as it runs in its own process group which can't read from the terminal.
+// Commands never ask for input anyway, they run with -input=false.
+func runTerraformCommand(ctx context.Context, t testing.TestingT, options *terraform.Options, args []string) CommandResult {
+	options.Logger.Logf(t, "Running command %s with args %s", options.TerraformBinary, args)
+
+	return NewCmdRunner().Run(ctx, Command{
+		Name:      options.TerraformBinary,
+		Args:      args,
+		Dir:       options.TerraformDir,
+		Env:       options.EnvVars,
+		Log:       terratestLogger{t: t, logger: options.Logger},
+		LogPrefix: fmt.Sprintf("%s %s", filepath.Base(options.TerraformBinary), args[0]),
+		WaitDelay: TERRAFORM_INTERRUPT_WAIT_DELAY,
+	})
+}
+
+// terratestLogger logs the output of commands with the logger of the
+// terraform options, like terratest does.
+type terratestLogger struct {
+	t      testing.TestingT
+	logger *logger.Logger
+}
+
+func (l terratestLogger) Logf(format string, args ...any) {
+	l.logger.Logf(l.t, format, args...)
+}